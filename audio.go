@@ -0,0 +1,9 @@
+package main
+
+type Audio interface {
+	Play(label string, gain, pitch float32)
+}
+
+type NullAudio struct{}
+
+func (NullAudio) Play(label string, gain, pitch float32) {}
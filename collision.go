@@ -0,0 +1,189 @@
+package main
+
+import mgl "github.com/go-gl/mathgl/mgl32"
+
+// Contact describes how two shapes overlap. Normal points from the first
+// shape to the second one, Depth is how far the second one has to move
+// along Normal to stop touching the first one.
+type Contact struct {
+	Point  mgl.Vec2
+	Normal mgl.Vec2
+	Depth  float32
+}
+
+// ConvexHullCollide tests a convex polygon against a hull of triangles.
+func ConvexHullCollide(poly, hull []mgl.Vec2) (bool, Contact) {
+	var found bool
+	var deepest Contact
+
+	for j := 0; j+2 < len(hull); j += 3 {
+		ok, contact := ConvexCollide(poly, hull[j:j+3])
+		if ok && (!found || contact.Depth > deepest.Depth) {
+			found = true
+			deepest = contact
+		}
+	}
+
+	return found, deepest
+}
+
+// ConvexCollide is a separating axis test for two convex polygons.
+func ConvexCollide(a, b []mgl.Vec2) (bool, Contact) {
+	if isDegenerate(a) || isDegenerate(b) {
+		return false, Contact{}
+	}
+
+	var contact Contact
+	contact.Depth = mgl.MaxValue
+	fromA := true
+
+	for k, poly := range [2][]mgl.Vec2{a, b} {
+		for i := range poly {
+			edge := poly[(i+1)%len(poly)].Sub(poly[i])
+			if edge.Len() < 1e-6 {
+				continue
+			}
+			axis := mgl.Vec2{-edge.Y(), edge.X()}.Normalize()
+
+			minA, maxA := project(a, axis)
+			minB, maxB := project(b, axis)
+			overlap := Min(maxA, maxB) - Max(minA, minB)
+			if overlap <= 0 {
+				return false, Contact{}
+			}
+
+			if overlap < contact.Depth {
+				if minB+maxB < minA+maxA {
+					axis = axis.Mul(-1) // keep normal pointing from a to b
+				}
+				contact.Depth = overlap
+				contact.Normal = axis
+				fromA = k == 0
+			}
+		}
+	}
+
+	if fromA {
+		contact.Point = support(b, contact.Normal.Mul(-1))
+	} else {
+		contact.Point = support(a, contact.Normal)
+	}
+	return true, contact
+}
+
+func project(poly []mgl.Vec2, axis mgl.Vec2) (min, max float32) {
+	min = poly[0].Dot(axis)
+	max = min
+	for _, p := range poly[1:] {
+		d := p.Dot(axis)
+		min = Min(min, d)
+		max = Max(max, d)
+	}
+	return min, max
+}
+
+func support(poly []mgl.Vec2, dir mgl.Vec2) mgl.Vec2 {
+	best := poly[0]
+	bestDot := best.Dot(dir)
+	for _, p := range poly[1:] {
+		if d := p.Dot(dir); d > bestDot {
+			best, bestDot = p, d
+		}
+	}
+	return best
+}
+
+func isDegenerate(poly []mgl.Vec2) bool {
+	var area float32
+	for i := range poly {
+		p1, p2 := poly[i], poly[(i+1)%len(poly)]
+		area += p1.X()*p2.Y() - p2.X()*p1.Y()
+	}
+	return mgl.Abs(area) < 1e-6
+}
+
+// HullsSweep finds when hull a moving by move first touches static hull b.
+// Time of impact is a fraction of move, 0 if the hulls already overlap.
+func HullsSweep(a, b []mgl.Vec2, move mgl.Vec2) (bool, float32, Contact) {
+	var found bool
+	var toi float32
+	var first Contact
+
+	for i := 0; i+2 < len(a); i += 3 {
+		for j := 0; j+2 < len(b); j += 3 {
+			ok, t, contact := ConvexSweep(a[i:i+3], b[j:j+3], move)
+			if ok && (!found || t < toi) {
+				found = true
+				toi = t
+				first = contact
+			}
+		}
+	}
+
+	return found, toi, first
+}
+
+// ConvexSweep is a separating axis test for convex polygon a moving by move
+// against static convex polygon b.
+func ConvexSweep(a, b []mgl.Vec2, move mgl.Vec2) (bool, float32, Contact) {
+	if isDegenerate(a) || isDegenerate(b) {
+		return false, 0, Contact{}
+	}
+
+	enter := -mgl.MaxValue
+	exit := mgl.MaxValue
+	var normal mgl.Vec2
+	fromA := true
+
+	for k, poly := range [2][]mgl.Vec2{a, b} {
+		for i := range poly {
+			edge := poly[(i+1)%len(poly)].Sub(poly[i])
+			if edge.Len() < 1e-6 {
+				continue
+			}
+			axis := mgl.Vec2{-edge.Y(), edge.X()}.Normalize()
+
+			minA, maxA := project(a, axis)
+			minB, maxB := project(b, axis)
+			speed := move.Dot(axis)
+
+			if mgl.Abs(speed) < 1e-6 {
+				if maxA <= minB || maxB <= minA {
+					return false, 0, Contact{}
+				}
+				continue
+			}
+
+			t0 := (minB - maxA) / speed
+			t1 := (maxB - minA) / speed
+			if t0 > t1 {
+				t0, t1 = t1, t0
+			}
+			if t0 > enter {
+				enter = t0
+				normal = axis
+				if speed < 0 {
+					normal = axis.Mul(-1) // a moves towards b along normal
+				}
+				fromA = k == 0
+			}
+			exit = Min(exit, t1)
+			if enter > exit || enter > 1 || exit < 0 {
+				return false, 0, Contact{}
+			}
+		}
+	}
+
+	if enter <= 0 {
+		ok, contact := ConvexCollide(a, b)
+		return ok, 0, contact
+	}
+
+	contact := Contact{Normal: normal}
+	if fromA {
+		contact.Point = support(b, normal.Mul(-1))
+	} else {
+		contact.Point = support(a, normal).Add(move.Mul(enter))
+	}
+	return true, enter, contact
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"math"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+)
+
+// Emitter shapes the shots of a gun, a gun without one fires a single
+// bullet along the ship. Bullets start at the gun speed and change it by
+// Accel pixels per second squared until they reach EndSpeed.
+type Emitter struct {
+	Pattern   string  // aimed, fan, ring, spiral or cone
+	Aim       bool    // turn fan, ring, spiral and cone to the nearest enemy
+	Count     int     // bullets per shot, default is 1
+	Spread    float32 // fan, cone: radians
+	Spin      float32 // spiral: radians per shot
+	SpeedStep float32 // every next bullet of a shot is faster by
+	Accel     float32
+	EndSpeed  float32
+}
+
+var emitterPatterns = map[string]bool{
+	"aimed": true, "fan": true, "ring": true, "spiral": true, "cone": true,
+}
+
+// Emit fires one shot from pos along dir, spin is the spiral angle of
+// the gun.
+func (e *Emitter) Emit(world *World, ship *Ship, gun *GunModel,
+	pos, dir mgl.Vec2, spin *float32) {
+
+	if e.Pattern == "aimed" || e.Aim {
+		target := world.NearestShip(pos, enemyRaces(ship.Race)...)
+		if target != nil {
+			if aim := direction(pos, target.Pos); aim != (mgl.Vec2{}) {
+				dir = aim
+			}
+		}
+	}
+
+	count := e.Count
+	if count < 1 {
+		count = 1
+	}
+	base := math.Atan2(float64(dir.Y()), float64(dir.X()))
+	for i := 0; i < count; i++ {
+		angle := base
+		switch e.Pattern {
+		case "fan":
+			if count > 1 {
+				k := float64(i)/float64(count-1) - 0.5
+				angle += k * float64(e.Spread)
+			}
+		case "ring":
+			angle += float64(i) * 2 * math.Pi / float64(count)
+		case "spiral":
+			angle += float64(*spin) + float64(i)*2*math.Pi/float64(count)
+		case "cone":
+			angle += float64((world.Rand.Float32() - 0.5) * e.Spread)
+		}
+
+		cos, sin := math.Cos(angle), math.Sin(angle)
+		bulletDir := mgl.Vec2{float32(cos), float32(sin)}
+		speed := gun.Speed + e.SpeedStep*float32(i)
+		v := bulletDir.Mul(speed)
+		m := world.NewMissile(ship.Race, pos, v, gun.Size, gun.Color)
+		m.accelerate(bulletDir, e.Accel, e.EndSpeed)
+		m.arm(gun)
+		world.AddMissiles(m)
+	}
+
+	if e.Pattern == "spiral" {
+		*spin = float32(math.Mod(float64(*spin+e.Spin), 2*math.Pi))
+	}
+}
+
+var (
+	humanEnemies = []Race{Others}
+	otherEnemies = []Race{Human, Autopilot}
+)
+
+// enemyRaces returns a shared slice, don't change it.
+func enemyRaces(race Race) []Race {
+	if race == Others {
+		return otherEnemies
+	}
+	return humanEnemies
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+)
+
+// Formation moves a group of ships as one unit. Members hold slots around
+// the anchor, survivors take the front slots when someone dies.
+type Formation struct {
+	Shape   string   // v, wedge, circle or grid
+	Spacing float32  // pixels between neighbour slots
+	Pos     mgl.Vec2 // anchor, the front of the formation
+	Speed   float32  // pixels per second to the left
+	StopX   float32  // fraction of the world width, 0 is never
+
+	// Members attack on their own after BreakTime seconds, when only
+	// BreakCount of them are left or when a player ship is closer than
+	// BreakRadius pixels to the anchor. Zero values never break.
+	BreakTime   float32
+	BreakCount  int
+	BreakRadius float32
+
+	members []*Ship
+	slotBuf []mgl.Vec2 // slots for the current member count
+	time    float32
+	broken  bool
+}
+
+// FormationPilot holds the ship in its formation slot and switches to
+// Attack when the formation breaks.
+type FormationPilot struct {
+	Formation *Formation
+	Attack    Pilot
+}
+
+var formationShapes = map[string]bool{
+	"v": true, "wedge": true, "circle": true, "grid": true,
+}
+
+// Form places ships in their slots and gives them formation pilots, attack
+// makes a pilot for every ship.
+func (f *Formation) Form(ships []*Ship, attack func(ship *Ship) Pilot) {
+	if !formationShapes[f.Shape] {
+		panic(fmt.Sprintf("unknown formation %q", f.Shape))
+	}
+
+	f.members = append([]*Ship(nil), ships...) // update drops the dead
+	f.slotBuf = nil
+	slots := f.slots(len(ships))
+	for i, ship := range ships {
+		ship.Pos = f.Pos.Add(slots[i])
+		ship.Pilot = &FormationPilot{Formation: f, Attack: attack(ship)}
+	}
+}
+
+// settings copies the formation without its members and progress.
+func (f *Formation) settings() Formation {
+	return Formation{
+		Shape:       f.Shape,
+		Spacing:     f.Spacing,
+		Pos:         f.Pos,
+		Speed:       f.Speed,
+		StopX:       f.StopX,
+		BreakTime:   f.BreakTime,
+		BreakCount:  f.BreakCount,
+		BreakRadius: f.BreakRadius,
+	}
+}
+
+func (f *Formation) Break() {
+	f.broken = true
+}
+
+func (f *Formation) Broken() bool {
+	return f.broken
+}
+
+func (f *Formation) update(dt float32, world *World) {
+	alive := f.members[:0]
+	for _, ship := range f.members {
+		if !ship.IsDead {
+			alive = append(alive, ship)
+		}
+	}
+	f.members = alive
+
+	f.time += dt
+	if f.StopX <= 0 || f.Pos.X() > world.Size.X()*f.StopX {
+		f.Pos[0] -= f.Speed * dt
+	}
+
+	switch {
+	case f.BreakTime > 0 && f.time >= f.BreakTime:
+		f.Break()
+	case f.BreakCount > 0 && len(f.members) <= f.BreakCount:
+		f.Break()
+	case f.BreakRadius > 0:
+		player := world.NearestShip(f.Pos, Human, Autopilot)
+		if player != nil && player.Pos.Sub(f.Pos).Len() < f.BreakRadius {
+			f.Break()
+		}
+	}
+}
+
+func (f *Formation) stopped(world *World) bool {
+	return f.StopX > 0 && f.Pos.X() <= world.Size.X()*f.StopX
+}
+
+// slots returns offsets from the anchor for n ships, the formation faces
+// to the left. The result is reused until n changes.
+func (f *Formation) slots(n int) []mgl.Vec2 {
+	if f.slotBuf != nil && len(f.slotBuf) == n {
+		return f.slotBuf
+	}
+	if cap(f.slotBuf) < n {
+		f.slotBuf = make([]mgl.Vec2, n)
+	}
+	slots := f.slotBuf[:n]
+	for i := range slots {
+		slots[i] = mgl.Vec2{}
+	}
+	f.slotBuf = slots
+	d := f.Spacing
+
+	switch f.Shape {
+	case "v":
+		for i := 1; i < n; i++ {
+			row := float32((i + 1) / 2)
+			side := float32(1 - 2*(i%2))
+			slots[i] = mgl.Vec2{row * d, side * row * d}
+		}
+	case "wedge":
+		for i, row := 0, 0; i < n; row++ {
+			for j := 0; j <= row && i < n; j, i = j+1, i+1 {
+				y := (float32(j) - float32(row)/2) * d
+				slots[i] = mgl.Vec2{float32(row) * d, y}
+			}
+		}
+	case "circle":
+		if n < 2 {
+			break
+		}
+		radius := Max(d*float32(n)/(2*math.Pi), d/2)
+		for i := range slots {
+			a := float64(i)*2*math.Pi/float64(n) + math.Pi
+			slots[i] = mgl.Vec2{
+				radius + radius*float32(math.Cos(a)),
+				radius * float32(math.Sin(a)),
+			}
+		}
+	case "grid":
+		rows := int(math.Ceil(math.Sqrt(float64(n))))
+		for i := range slots {
+			row, col := i%rows, i/rows
+			y := (float32(row) - float32(rows-1)/2) * d
+			slots[i] = mgl.Vec2{float32(col) * d, y}
+		}
+	}
+	return slots
+}
+
+func (p *FormationPilot) Update(dt float32, ship *Ship, world *World) {
+	f := p.Formation
+	slot, count := -1, 0
+	for _, member := range f.members {
+		if member == ship {
+			slot = count
+		}
+		if !member.IsDead {
+			count++
+		}
+	}
+
+	if !f.broken && slot == 0 {
+		f.update(dt, world) // the leader moves the formation
+	}
+	if f.broken {
+		if p.Attack != nil {
+			p.Attack.Update(dt, ship, world)
+		}
+		return
+	}
+	if slot < 0 || dt <= 0 {
+		return
+	}
+
+	target := f.Pos.Add(f.slots(count)[slot])
+	move := target.Sub(ship.Pos).Mul(1 / (ship.model.Speed * dt))
+	ship.Control(move, f.stopped(world))
+}
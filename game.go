@@ -1,13 +1,27 @@
 package main
 
+import mgl "github.com/go-gl/mathgl/mgl32"
+
+const (
+	StepRate = 120
+	StepDT   = float32(1.0 / StepRate)
+)
+
 type Game struct {
-	world *World
-	input *Input
-	ship  *Ship
+	world    *World
+	controls *Controls
+	ship     *Ship
+	stars    Starfield
 
 	stage     Stage
 	stages    []Stage
 	nextStage int
+	finished  bool
+}
+
+type Controls struct {
+	Dir  mgl.Vec2
+	Fire bool
 }
 
 type Stage interface {
@@ -15,27 +29,27 @@ type Stage interface {
 	Update(dt float32, world *World) bool
 }
 
-func NewGame(world *World, input *Input) *Game {
+func NewGame(world *World, controls *Controls) *Game {
 	game := &Game{
-		world: world,
-		input: input,
+		world:    world,
+		controls: controls,
 	}
 
 	game.ship = NewShip(Human, &PlayerModel)
 	game.world.AddShips(game.ship)
 
-	starfield := NewStarfield(world.Size.X(), world.Size.Y())
-	game.world.AddObjects(starfield)
+	game.stars = NewStarfield(world.Size.X(), world.Size.Y(), world.FxRand)
+	game.world.AddObjects(game.stars)
 
 	game.stages = []Stage{
-		&IntroStage{Stars: starfield, Ship: game.ship},
+		&IntroStage{Stars: game.stars, Ship: game.ship},
 		&CargoStage{MinCount: 2, MaxCount: 2},
 		&CargoStage{MinCount: 5, MaxCount: 8},
 		&CargoStage{MinCount: 6, MaxCount: 8},
 		&FighterStage{},
 		&CargoStage{MinCount: 6, MaxCount: 8},
 		&FinalStage{},
-		&OutroStage{Stars: starfield, Ship: game.ship},
+		&OutroStage{Stars: game.stars, Ship: game.ship},
 	}
 
 	return game
@@ -43,7 +57,7 @@ func NewGame(world *World, input *Input) *Game {
 
 func (game *Game) Update(dt float32) {
 	if game.ship.Race == Human {
-		game.ship.Control(game.input.Dir, game.input.Fire)
+		game.ship.Control(game.controls.Dir, game.controls.Fire)
 	}
 
 	if game.stage == nil {
@@ -58,15 +72,33 @@ func (game *Game) Update(dt float32) {
 
 	ok := game.stage.Update(dt, game.world)
 	if !ok {
+		game.finished = game.finished || game.nextStage == 0
 		game.stage = nil
 	}
 
 	game.world.Update(dt)
 }
 
+func (game *Game) SetLevel(level *Level) error {
+	stages, err := level.Build(game)
+	if err != nil {
+		return err
+	}
+
+	game.stages = stages
+	game.stage = nil
+	game.nextStage = 0
+	game.finished = false
+	return nil
+}
+
 func (game *Game) initNextStage() {
 	game.stage = game.stages[game.nextStage]
 	game.nextStage = (game.nextStage + 1) % len(game.stages)
 
 	game.stage.Init(game.world)
 }
+
+func (game *Game) Finished() bool {
+	return game.finished
+}
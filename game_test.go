@@ -0,0 +1,46 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestDeterminism plays a recorded run again with the same seed, the games
+// must match at every step.
+func TestDeterminism(t *testing.T) {
+	const seed = 7
+
+	var controls, replayed Controls
+	game := NewGame(NewWorld(width, height, NullAudio{}, seed), &controls)
+	replay := NewReplay(seed, ReplayInputs{})
+	player := replay.Player()
+	again := NewGame(NewWorld(width, height, NullAudio{}, seed), &replayed)
+	other := NewGame(NewWorld(width, height, NullAudio{}, seed+1),
+		&replayed)
+
+	diverged := false
+	for step := 0; step < StepRate*60; step++ {
+		live := Controls{Fire: step%90 < 60}
+		live.Dir[0] = float32(step/150%3 - 1)
+		live.Dir[1] = float32(step/100%3-1) * 0.5
+		controls = StepControls(live, nil, replay)
+		game.Update(StepDT)
+
+		replayed = StepControls(Controls{}, player, nil)
+		again.Update(StepDT)
+		other.Update(StepDT)
+
+		if step%StepRate != 0 {
+			continue
+		}
+		snap := game.Snapshot()
+		if !reflect.DeepEqual(snap, again.Snapshot()) {
+			t.Fatalf("games differ at step %d", step)
+		}
+		diverged = diverged ||
+			!reflect.DeepEqual(snap.Ships, other.Snapshot().Ships)
+	}
+	if !diverged {
+		t.Error("another seed plays the same game")
+	}
+}
@@ -0,0 +1,13 @@
+//go:build headless
+// +build headless
+
+package main
+
+// The headless build leaves out the window, renderer and sound, so it
+// needs none of GLFW, OpenGL and OpenAL.
+
+const windowSupport = false
+
+func RunWindowed(opts *Options) {
+	panic("built with the headless tag, run with -headless")
+}
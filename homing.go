@@ -0,0 +1,58 @@
+package main
+
+import mgl "github.com/go-gl/mathgl/mgl32"
+
+// Homing makes missiles chase the nearest enemy in front of them. A new
+// target is picked when the old one dies, after Fuel seconds missiles fly
+// straight.
+type Homing struct {
+	Cone       float32 // radians to each side of the missile
+	TurnRate   float32 // radians per second
+	Fuel       float32
+	Smoke      float32 // trail particles per second, 0 is none
+	SmokeColor mgl.Vec4
+}
+
+func (m *Missile) home(dt float32, world *World) {
+	h := m.homing
+	m.fuel -= dt
+
+	speed := m.velocity.Len()
+	if speed < 1e-3 {
+		return
+	}
+	heading := m.velocity.Mul(1 / speed)
+
+	if m.target == nil || m.target.IsDead {
+		races := enemyRaces(m.race)
+		m.target = world.NearestShipInCone(m.pos, heading, h.Cone, races...)
+	}
+	if m.target != nil {
+		if want := direction(m.pos, m.target.Pos); want != (mgl.Vec2{}) {
+			turn := h.TurnRate * dt
+			angle := mgl.Clamp(angleBetween(heading, want), -turn, turn)
+			heading = mgl.Rotate2D(angle).Mul2x1(heading).Normalize()
+		}
+	}
+	m.velocity = heading.Mul(speed)
+	if m.accel != 0 {
+		m.dir = heading
+	}
+
+	m.smoke(dt, world, heading)
+}
+
+func (m *Missile) smoke(dt float32, world *World, heading mgl.Vec2) {
+	const ttl = 0.4
+
+	h := m.homing
+	m.smokeCD -= dt
+	for ; h.Smoke > 0 && m.smokeCD < 0; m.smokeCD += 1 / h.Smoke {
+		tail := m.pos.Sub(heading.Mul(m.size.X() / 2))
+		size := m.size.Y()
+		particle := world.NewParticle(tail, size, 2*size,
+			ttl+ttl*world.FxRand.Float32(), h.SmokeColor)
+		particle.RenderGroup = EngineGroup
+		world.AddObjects(particle)
+	}
+}
@@ -1,3 +1,6 @@
+//go:build !headless
+// +build !headless
+
 package main
 
 import (
@@ -6,15 +9,20 @@ import (
 )
 
 type Input struct {
+	Controls
+
 	window *glfw.Window
 	useJoy bool
 
-	Dir               mgl.Vec2
-	Fire              bool
 	Debug             bool
 	DebugToggled      bool
 	Fullscreen        bool
 	FullscreenToggled bool
+	QuickSave         bool
+	QuickLoad         bool
+	PauseToggled      bool
+	StepBack          bool
+	StepForward       bool
 }
 
 func NewInput(w *glfw.Window, fullscreen bool) *Input {
@@ -29,6 +37,11 @@ func NewInput(w *glfw.Window, fullscreen bool) *Input {
 func (i *Input) Process() {
 	i.DebugToggled = false
 	i.FullscreenToggled = false
+	i.QuickSave = false
+	i.QuickLoad = false
+	i.PauseToggled = false
+	i.StepBack = false
+	i.StepForward = false
 	glfw.PollEvents()
 
 	if i.IsPressed(glfw.KeyEscape) {
@@ -93,6 +106,16 @@ func (i *Input) GetKeyCallback() glfw.KeyCallback {
 		} else if key == glfw.KeyF && action == glfw.Press {
 			i.Fullscreen = !i.Fullscreen
 			i.FullscreenToggled = true
+		} else if key == glfw.KeyF5 && action == glfw.Press {
+			i.QuickSave = true
+		} else if key == glfw.KeyF9 && action == glfw.Press {
+			i.QuickLoad = true
+		} else if key == glfw.KeyP && action == glfw.Press {
+			i.PauseToggled = true
+		} else if key == glfw.KeyComma && action != glfw.Release {
+			i.StepBack = true
+		} else if key == glfw.KeyPeriod && action != glfw.Release {
+			i.StepForward = true
 		}
 	}
 	return cb
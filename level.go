@@ -0,0 +1,431 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+)
+
+// Level is a campaign loaded with -level. Keys are matched to field names
+// case-insensitively, unknown keys are errors. Positions are fractions of
+// the world size, speeds are fractions of the ship model speed.
+type Level struct {
+	Paths  map[string]PathDef // shared by all stages
+	Stages []StageDef
+}
+
+type StageDef struct {
+	Type     string     // intro, outro, cargo, fighters, final or wave
+	MinCount int        // cargo: ship count, default is 1
+	MaxCount int        // cargo: only takes a random number, see CargoStage
+	Spawns   []SpawnDef // wave
+	Until    string     // wave: "cleared" (default) or "time"
+	Time     float32    // wave: seconds to last with "time"
+	Boss     string     // final: ship model, default is papa
+	Phases   []PhaseDef // final: default is the papa fight
+}
+
+// PhaseDef is a part of a boss fight. Phases go in order, the next one
+// starts when any of its conditions is met.
+type PhaseDef struct {
+	Health     float32    // the boss health drops to it
+	Time       float32    // seconds in the previous phase after its transition
+	Part       string     // the boss part is destroyed
+	Transition float32    // invulnerable seconds before the phase
+	Pilot      PilotDef   // how the boss moves
+	Speed      float32    // pilot speed
+	Guns       []int      // guns to fire from 0, empty is all
+	Escorts    []SpawnDef // spawned when the phase starts
+	Respawn    bool       // spawn escorts again when they are dead
+}
+
+type SpawnDef struct {
+	Models    []string // a random one for every ship
+	Count     int
+	MaxCount  int           // random count from Count to MaxCount
+	Delay     float32       // seconds since the stage start
+	X         float32       // default is just behind the right edge
+	Y         [2]float32    // ships are spread evenly, default is [0, 1]
+	Speed     [2]float32    // random speed from min to max
+	Pilot     PilotDef      // with Formation: what to do after the break
+	Formation *FormationDef // fly together, at the min speed
+}
+
+type PilotDef struct {
+	Type     string  // straight (default), stop, round, steer or path
+	Fire     bool    // fire from the start, steer: fire all the time
+	StopX    float32 // stop, round: where to stop
+	AngleMin float32 // round: firing sector in degrees
+	AngleMax float32
+
+	Steering  []SteeringDef // steer: behaviours to blend
+	Aim       bool          // steer: turn to the player, fire when aimed
+	TurnRate  float32       // steer: degrees per second
+	FireAngle float32       // steer: degrees
+
+	Path   string   // path: name of a level path
+	Mirror bool     // path: flip it vertically
+	Route  *PathDef `json:"-"` // path: resolved by Level.Build
+}
+
+type FormationDef struct {
+	Shape       string  // v, wedge, circle or grid
+	Spacing     float32 // pixels, default is 60
+	StopX       float32 // where to stop, default is never
+	BreakTime   float32 // seconds
+	BreakCount  int     // ships left
+	BreakRadius float32 // pixels to the player
+}
+
+type SteeringDef struct {
+	Type      string     // seek, flee, arrive, strafe or orbit
+	Weight    float32    // default is 1
+	Target    [2]float32 // fractions of the world size
+	Player    bool       // target the player instead
+	Radius    float32    // flee, arrive, orbit: pixels
+	Period    float32    // strafe: seconds
+	Clockwise bool       // orbit
+}
+
+func LoadLevel(path string) (*Level, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	level := &Level{}
+	decoder := json.NewDecoder(f)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(level); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return level, nil
+}
+
+func (level *Level) Build(game *Game) ([]Stage, error) {
+	if len(level.Stages) == 0 {
+		return nil, errors.New("level has no stages")
+	}
+	for name, path := range level.Paths {
+		if err := path.Validate(); err != nil {
+			return nil, fmt.Errorf("path %q: %v", name, err)
+		}
+		level.Paths[name] = path
+	}
+
+	stages := make([]Stage, len(level.Stages))
+	for i := range level.Stages {
+		stage, err := level.Stages[i].build(game, level.Paths)
+		if err != nil {
+			return nil, fmt.Errorf("stage %d: %v", i+1, err)
+		}
+		stages[i] = stage
+	}
+	return stages, nil
+}
+
+func (def *StageDef) build(game *Game,
+	paths map[string]PathDef) (Stage, error) {
+
+	switch def.Type {
+	case "intro":
+		return &IntroStage{Stars: game.stars, Ship: game.ship}, nil
+	case "outro":
+		return &OutroStage{Stars: game.stars, Ship: game.ship}, nil
+	case "cargo":
+		return def.buildCargo()
+	case "fighters":
+		return &FighterStage{}, nil
+	case "final":
+		stage := &FinalStage{Boss: def.Boss, Phases: def.Phases}
+		if err := stage.normalize(paths); err != nil {
+			return nil, err
+		}
+		return stage, nil
+	case "wave":
+		return def.buildWave(paths)
+	}
+	return nil, fmt.Errorf("unknown stage type %q", def.Type)
+}
+
+func (def *StageDef) buildCargo() (Stage, error) {
+	stage := &CargoStage{MinCount: def.MinCount, MaxCount: def.MaxCount}
+	if stage.MinCount < 0 || stage.MaxCount < 0 {
+		return nil, errors.New("negative ship count")
+	}
+	if stage.MinCount == 0 {
+		stage.MinCount = 1
+	}
+	if stage.MaxCount < stage.MinCount {
+		stage.MaxCount = stage.MinCount
+	}
+	return stage, nil
+}
+
+func (def *StageDef) buildWave(paths map[string]PathDef) (Stage, error) {
+	stage := &WaveStage{
+		Spawns: make([]SpawnDef, len(def.Spawns)),
+		Until:  def.Until,
+		Time:   def.Time,
+	}
+
+	switch stage.Until {
+	case "":
+		stage.Until = "cleared"
+	case "cleared", "time":
+	default:
+		return nil, fmt.Errorf("unknown completion condition %q", def.Until)
+	}
+
+	for i, spawn := range def.Spawns {
+		if err := spawn.normalize(paths); err != nil {
+			return nil, fmt.Errorf("spawn %d: %v", i+1, err)
+		}
+		stage.Spawns[i] = spawn
+	}
+	sort.SliceStable(stage.Spawns, func(i, j int) bool {
+		return stage.Spawns[i].Delay < stage.Spawns[j].Delay
+	})
+
+	return stage, nil
+}
+
+func (spawn *SpawnDef) normalize(paths map[string]PathDef) error {
+	const posX = 1.1
+
+	if len(spawn.Models) == 0 {
+		return errors.New("no ship models")
+	}
+	for _, name := range spawn.Models {
+		if _, found := ShipModels[name]; !found {
+			return fmt.Errorf("unknown ship model %q", name)
+		}
+	}
+
+	if spawn.Count < 0 || spawn.MaxCount < 0 {
+		return errors.New("negative ship count")
+	}
+	if spawn.Count == 0 {
+		spawn.Count = 1
+	}
+	if spawn.MaxCount < spawn.Count {
+		spawn.MaxCount = spawn.Count
+	}
+
+	if spawn.X == 0 {
+		spawn.X = posX
+	}
+	if spawn.Y == [2]float32{} {
+		spawn.Y = [2]float32{0, 1}
+	}
+
+	if spawn.Formation != nil {
+		if err := spawn.Formation.normalize(); err != nil {
+			return err
+		}
+	}
+	return spawn.Pilot.normalize(paths)
+}
+
+// normalize fills in the default boss and phases.
+func (s *FinalStage) normalize(paths map[string]PathDef) error {
+	if s.Boss == "" {
+		s.Boss = "papa"
+	}
+	model, found := ShipModels[s.Boss]
+	if !found {
+		return fmt.Errorf("unknown ship model %q", s.Boss)
+	}
+	if len(s.Phases) == 0 {
+		s.Phases = papaPhases()
+	}
+
+	for i := range s.Phases {
+		if err := s.Phases[i].normalize(paths, model); err != nil {
+			return fmt.Errorf("phase %d: %v", i+1, err)
+		}
+	}
+	return nil
+}
+
+func (def *PhaseDef) normalize(paths map[string]PathDef,
+	boss *ShipModel) error {
+
+	if def.Health < 0 || def.Health > 1 {
+		return errors.New("health must be 0 to 1")
+	}
+	if def.Time < 0 || def.Transition < 0 {
+		return errors.New("negative time")
+	}
+	if def.Part != "" && !hasPart(boss, def.Part) {
+		return fmt.Errorf("unknown part %q", def.Part)
+	}
+	for _, gun := range def.Guns {
+		if gun < 0 || gun >= len(boss.Guns) {
+			return fmt.Errorf("no gun %d", gun)
+		}
+	}
+
+	for i := range def.Escorts {
+		if err := def.Escorts[i].normalize(paths); err != nil {
+			return fmt.Errorf("escort %d: %v", i+1, err)
+		}
+	}
+	return def.Pilot.normalize(paths)
+}
+
+func hasPart(model *ShipModel, name string) bool {
+	for _, part := range model.Parts {
+		if part.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (def *FormationDef) normalize() error {
+	const spacing = 60
+
+	if !formationShapes[def.Shape] {
+		return fmt.Errorf("unknown formation %q", def.Shape)
+	}
+	if def.Spacing == 0 {
+		def.Spacing = spacing
+	}
+	if def.BreakCount < 0 {
+		return errors.New("negative break count")
+	}
+	return nil
+}
+
+func (def *PilotDef) normalize(paths map[string]PathDef) error {
+	const stopX = 0.9
+	const turnRate = 90
+	const fireAngle = 10
+
+	switch def.Type {
+	case "":
+		def.Type = "straight"
+	case "straight", "stop", "round":
+	case "steer":
+		if len(def.Steering) == 0 && !def.Aim {
+			return errors.New("steer pilot does nothing")
+		}
+	case "path":
+		path, found := paths[def.Path]
+		if !found {
+			return fmt.Errorf("unknown path %q", def.Path)
+		}
+		def.Route = &path
+	default:
+		return fmt.Errorf("unknown pilot type %q", def.Type)
+	}
+	if def.StopX == 0 {
+		def.StopX = stopX
+	}
+	if def.TurnRate == 0 {
+		def.TurnRate = turnRate
+	}
+	if def.FireAngle == 0 {
+		def.FireAngle = fireAngle
+	}
+
+	for i := range def.Steering {
+		steering := &def.Steering[i]
+		switch steering.Type {
+		case "seek", "flee", "arrive", "strafe", "orbit":
+		default:
+			return fmt.Errorf("unknown steering %q", steering.Type)
+		}
+		if steering.Weight == 0 {
+			steering.Weight = 1
+		}
+	}
+
+	return nil
+}
+
+// NewPilot makes a pilot for a ship that enters with speed.
+func (def *PilotDef) NewPilot(ship *Ship, speed float32, world *World) Pilot {
+	switch def.Type {
+	case "path":
+		return &PathFollower{
+			Path:   NewPath(*def.Route, world.Size),
+			Speed:  speed,
+			Mirror: def.Mirror,
+		}
+	case "stop":
+		return &Advancer{Speed: speed, StopX: def.StopX, Fire: def.Fire}
+	case "round":
+		return NewRoundShooter(ship, def.StopX, mgl.DegToRad(def.AngleMin),
+			mgl.DegToRad(def.AngleMax))
+	case "steer":
+		pilot := &SteeringPilot{Fire: def.Fire}
+		var blend Blend
+		for _, steering := range def.Steering {
+			blend = append(blend, Weighted{
+				Steering: steering.build(),
+				Weight:   steering.Weight * speed,
+			})
+		}
+		if len(blend) > 0 {
+			pilot.Move = blend
+		}
+		if def.Aim {
+			pilot.Aim = &AimAtPlayer{
+				TurnRate:  mgl.DegToRad(def.TurnRate),
+				FireAngle: mgl.DegToRad(def.FireAngle),
+			}
+		}
+		return pilot
+	}
+	return &Advancer{Speed: speed, Fire: def.Fire}
+}
+
+// build makes a formation at the spawn position, it is as fast as the
+// slowest ship at speed.
+func (def *FormationDef) build(ships []*Ship, speed float32,
+	world *World) *Formation {
+
+	var pos mgl.Vec2
+	modelSpeed := float32(math.MaxFloat32)
+	for _, ship := range ships {
+		pos = pos.Add(ship.Pos.Mul(1 / float32(len(ships))))
+		modelSpeed = Min(modelSpeed, ship.model.Speed)
+	}
+
+	return &Formation{
+		Shape:       def.Shape,
+		Spacing:     def.Spacing,
+		Pos:         pos,
+		Speed:       speed * modelSpeed,
+		StopX:       def.StopX,
+		BreakTime:   def.BreakTime,
+		BreakCount:  def.BreakCount,
+		BreakRadius: def.BreakRadius,
+	}
+}
+
+func (def *SteeringDef) build() Steering {
+	target := Target{Pos: mgl.Vec2(def.Target), Player: def.Player}
+	switch def.Type {
+	case "seek":
+		return &Seek{Target: target}
+	case "flee":
+		return &Flee{Target: target, Radius: def.Radius}
+	case "arrive":
+		return &Arrive{Target: target, Radius: def.Radius}
+	case "strafe":
+		return &Strafe{Target: target, Period: def.Period}
+	case "orbit":
+		return &Orbit{Target: target, Radius: def.Radius,
+			Clockwise: def.Clockwise}
+	}
+	panic("unknown steering " + def.Type)
+}
@@ -3,83 +3,170 @@ package main
 import (
 	"flag"
 	"fmt"
-	"math/rand"
 	"os"
-	"runtime"
 	"time"
+)
+
+const (
+	title  = "Shmup"
+	width  = 1366
+	height = 768
 
-	"github.com/go-gl/gl/v3.2-core/gl"
-	"github.com/go-gl/glfw/v3.1/glfw"
+	rewindSeconds = 10
+	quickSavePath = "quicksave.snap"
+	crashSavePath = "crash.snap"
 )
 
-func init() {
-	runtime.LockOSThread() // GLFW event handling must run on the main OS thread
-	rand.Seed(time.Now().UnixNano())
+var Version = "dev" // set with -ldflags "-X main.Version=..."
+
+type Options struct {
+	Fullscreen bool
+	Steps      int
+	Seed       int64
+	Level      string
+	Models     string
+	Snapshot   string
+	Player     *ReplayPlayer
+	Recorder   *Replay
 }
 
 func main() {
-	const (
-		title  = "Shmup"
-		width  = 1366
-		height = 768
-	)
+	if len(os.Args) > 1 && os.Args[1] == "shipview" {
+		if err := RunShipView(os.Args[2:]); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
 
-	fullscreen := flag.Bool("fs", false, "fullscreen mode")
+	var opts Options
+	flag.BoolVar(&opts.Fullscreen, "fs", false, "fullscreen mode")
+	flag.IntVar(&opts.Steps, "steps", StepRate*60*10,
+		"max simulation steps in headless mode")
+	flag.Int64Var(&opts.Seed, "seed", time.Now().UnixNano(), "random seed")
+	flag.StringVar(&opts.Level, "level", "", "load stages from level file")
+	flag.StringVar(&opts.Models, "models", "",
+		"load ship models from file, reload it on changes")
+	flag.StringVar(&opts.Snapshot, "snapshot", "", "start from snapshot file")
+	headless := flag.Bool("headless", !windowSupport,
+		"run simulation without window and sound")
+	replayPath := flag.String("replay", "", "play back replay file")
+	recordPath := flag.String("record", "", "record replay to file")
+	exportPath := flag.String("export-models", "",
+		"save built-in ship models to file and exit")
 	flag.Parse()
 
 	defer HandlePanic()
 
-	PanicOnError(InitGLFW())
-	defer glfw.Terminate()
+	if *exportPath != "" {
+		PanicOnError(SaveModels(*exportPath))
+		return
+	}
+
+	inputs, err := HashInputs(opts.Level, opts.Models, opts.Snapshot)
+	PanicOnError(err)
+
+	if *replayPath != "" {
+		replay, err := LoadReplay(*replayPath)
+		PanicOnError(err)
+		PanicOnError(inputs.Check(replay.Inputs))
+		if replay.Version != Version {
+			fmt.Printf("replay was recorded by version %q, running %q\n",
+				replay.Version, Version)
+		}
+		opts.Seed = replay.Seed
+		opts.Player = replay.Player()
+	}
+
+	if *recordPath != "" {
+		opts.Recorder = NewReplay(opts.Seed, inputs)
+	}
+
+	if *headless {
+		RunHeadless(&opts)
+	} else {
+		RunWindowed(&opts)
+	}
+
+	if opts.Recorder != nil {
+		PanicOnError(opts.Recorder.Save(*recordPath))
+	}
+}
 
-	window, screenSize := NewWindow(width, height, title, *fullscreen)
-	PanicOnError(gl.Init())
+func RunHeadless(opts *Options) {
+	player, recorder := opts.Player, opts.Recorder
 
-	InitSound()
-	defer TerminateSound()
-	LoadSoundAssets("*.wav")
+	var controls Controls
+	var renderer NullRenderer
+	world := NewWorld(width, height, NullAudio{}, opts.Seed)
+	game := NewGame(world, &controls)
 
-	input := NewInput(window, *fullscreen)
-	renderer := NewRenderer(width, height, screenSize)
-	world := NewWorld(width, height)
-	game := NewGame(world, input)
-	timer := NewTimer()
+	defer SaveOnPanic(game)
+	SetupGame(game, opts)
 
-	for !window.ShouldClose() {
-		renderer.Clear()
-		game.Update(timer.DT)
+	step := 0
+	for ; step < opts.Steps && !game.Finished(); step++ {
+		if player != nil && player.Done() {
+			break
+		}
+		controls = StepControls(Controls{}, player, recorder)
+		game.Update(StepDT)
 		world.Draw(renderer)
-		renderer.Render()
+	}
 
-		window.SwapBuffers()
+	fmt.Printf("seed: %d, steps: %d, time: %.2fs, stage: %d, ships: %d, "+
+		"finished: %v\n", opts.Seed, step, float32(step)*StepDT, game.nextStage,
+		world.ShipCount(), game.Finished())
+}
 
-		timer.Tick()
-		if timer.TicksCount == 60 {
-			window.SetTitle(timer.Stat())
-			timer.ResetCounter()
+func SetupGame(game *Game, opts *Options) {
+	if opts.Models != "" {
+		models, err := LoadModels(opts.Models)
+		PanicOnError(err)
+		ApplyModels(models)
+		game.world.SyncModels()
+	}
+	if opts.Level != "" {
+		level, err := LoadLevel(opts.Level)
+		PanicOnError(err)
+		PanicOnError(game.SetLevel(level))
+	}
+	if opts.Snapshot != "" {
+		PanicOnError(game.LoadSnapshot(opts.Snapshot))
+	}
+}
+
+// StepControls picks controls for the next simulation step: replayed ones
+// while the replay lasts, live ones after that.
+func StepControls(live Controls, player *ReplayPlayer,
+	recorder *Replay) Controls {
+
+	controls := live
+	if player != nil {
+		if replayed, ok := player.Next(); ok {
+			controls = replayed
 		}
+	}
+	if recorder != nil {
+		recorder.Record(controls)
+	}
+	return controls
+}
 
-		input.Process()
-		switch {
-		case input.DebugToggled && input.Debug:
-			glfw.SwapInterval(0)
-		case input.DebugToggled:
-			glfw.SwapInterval(1)
-		case input.FullscreenToggled:
-			//renderer.Cleanup()
-			window.Destroy()
-			window, screenSize = NewWindow(width, height, title,
-				input.Fullscreen)
-			input.SetWindow(window)
-			renderer = NewRenderer(width, height, screenSize)
+// SaveOnPanic dumps the game state next to the crash message, so the crash
+// can be reproduced with -snapshot.
+func SaveOnPanic(game *Game) {
+	if err := recover(); err != nil {
+		if saveErr := game.SaveSnapshot(crashSavePath); saveErr == nil {
+			fmt.Println("game state saved to", crashSavePath)
 		}
+		panic(err)
 	}
 }
 
 func HandlePanic() {
 	if err := recover(); err != nil {
 		fmt.Println(err)
-		glfw.Terminate()
 		os.Exit(1)
 	}
 }
@@ -1,6 +1,10 @@
 package main
 
-import mgl "github.com/go-gl/mathgl/mgl32"
+import (
+	"math"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+)
 
 type Missile struct {
 	IsDead   bool
@@ -9,70 +13,201 @@ type Missile struct {
 	velocity mgl.Vec2
 	size     mgl.Vec2
 	color    mgl.Vec4
+
+	dir      mgl.Vec2
+	accel    float32
+	endSpeed float32
+
+	damage       int
+	pierce       int     // ships left to pass through
+	blast        float32 // radius of the area damage
+	friendlyFire bool
+	pierced      []*Ship
+	piercedBuf   [4]*Ship
+
+	homing  *Homing
+	target  *Ship
+	fuel    float32 // seconds of homing left
+	smokeCD float32
 }
 
-func NewMissile(race Race, pos, velocity, size mgl.Vec2, color mgl.Vec4) *Missile {
-	return &Missile{
+func (m *Missile) init(race Race, pos, velocity, size mgl.Vec2, color mgl.Vec4) {
+	pierced := m.pierced[:0] // pooled missiles keep their buffer
+	*m = Missile{
 		race:     race,
 		pos:      pos,
 		velocity: velocity,
 		size:     size,
 		color:    color,
+		damage:   1,
+		pierced:  pierced,
+	}
+	if m.pierced == nil {
+		m.pierced = m.piercedBuf[:0]
+	}
+}
+
+// arm takes the damage settings of the gun.
+func (m *Missile) arm(gun *GunModel) {
+	if gun.Damage > 0 {
+		m.damage = gun.Damage
+	}
+	m.pierce = gun.Pierce
+	m.blast = gun.Blast
+	m.friendlyFire = gun.FriendlyFire
+	if gun.Homing != nil {
+		m.homing = gun.Homing
+		m.fuel = gun.Homing.Fuel
 	}
 }
 
-func (m *Missile) Update(dt float32, world *World, ships []*Ship) {
+// accelerate changes the missile speed along dir until it is endSpeed.
+func (m *Missile) accelerate(dir mgl.Vec2, accel, endSpeed float32) {
+	m.dir = dir
+	m.accel = mgl.Abs(accel)
+	m.endSpeed = endSpeed
+}
+
+func (m *Missile) Update(dt float32, world *World) {
+	if m.homing != nil && m.fuel > 0 {
+		m.home(dt, world)
+	}
+	if m.accel != 0 {
+		speed := m.velocity.Dot(m.dir)
+		if speed < m.endSpeed {
+			speed = Min(speed+m.accel*dt, m.endSpeed)
+		} else {
+			speed = Max(speed-m.accel*dt, m.endSpeed)
+		}
+		m.velocity = m.dir.Mul(speed)
+	}
+
 	newPos := m.pos.Add(m.velocity.Mul(dt))
 	aabb := m.AABB(m.velocity.Mul(dt))
 
-	var hitShip *Ship
-	var hitPos mgl.Vec2
-	var hitDistance = mgl.MaxValue
-
-	for _, s := range ships {
-		if m.race == s.Race {
+	sweep := m.sweep(newPos)
+	for _, s := range world.ShipsNear(aabb) {
+		if m.race == s.Race || m.hasPierced(s) {
 			continue
 		}
 		if !CheckAABB(aabb, s.AABB()) {
 			continue
 		}
-		for _, side := range s.Sides() {
-			ok, point := m.intersection(newPos, side[0], side[1])
-			if ok {
-				hitShip = s
-				distance := m.pos.Sub(point).Len()
-				if hitDistance > distance {
-					hitPos = point
-					hitDistance = distance
-				}
+		ok, part, contact := s.hitTest(sweep[:])
+		if !ok {
+			continue
+		}
+
+		hitPos := contact.Point
+		if part < 0 {
+			hitPos = m.entryPoint(s, newPos, hitPos)
+		}
+		m.hit(world, s, part, hitPos)
+		if m.IsDead {
+			return
+		}
+	}
+	m.pos = newPos
+}
+
+// entryPoint prefers the point where the missile enters the hull, if it
+// does.
+func (m *Missile) entryPoint(s *Ship, newPos, hitPos mgl.Vec2) mgl.Vec2 {
+	hitDistance := mgl.MaxValue
+	for _, side := range s.Sides() {
+		ok, point := m.intersection(newPos, side[0], side[1])
+		if ok {
+			distance := m.pos.Sub(point).Len()
+			if hitDistance > distance {
+				hitPos = point
+				hitDistance = distance
 			}
 		}
-		if hitShip != nil {
-			break
+	}
+	return hitPos
+}
+
+func (m *Missile) hit(world *World, ship *Ship, part int, pos mgl.Vec2) {
+	const ttl = 0.05
+	size := m.size.Y() * 3
+
+	ship.HitPart(part, m.damage)
+	explosion := world.NewParticle(pos, size, size, ttl, m.color)
+	world.AddObjects(explosion)
+
+	if m.pierce > 0 {
+		m.pierce -= 1
+		m.pierced = append(m.pierced, ship)
+		return
+	}
+	m.IsDead = true
+	if m.blast > 0 {
+		m.explode(world, pos, ship)
+	}
+}
+
+func (m *Missile) hasPierced(ship *Ship) bool {
+	for _, s := range m.pierced {
+		if s == ship {
+			return true
 		}
 	}
+	return false
+}
 
-	if hitShip == nil {
-		m.pos = newPos
-	} else {
-		const ttl = 0.05
-		size := m.size.Y() * 3
+// explode damages ships in the blast radius besides the one hit directly.
+// Without friendly fire only enemies of the missile race are damaged.
+func (m *Missile) explode(world *World, pos mgl.Vec2, hitShip *Ship) {
+	const (
+		ttl   = 0.15
+		sides = 12
+	)
 
-		hitShip.Hit()
-		m.IsDead = true
-		explosion := NewParticle(hitPos, size, size, ttl, m.color)
-		world.AddObjects(explosion)
+	r := m.blast
+	var blast [sides]mgl.Vec2
+	for i := range blast {
+		sin, cos := math.Sincos(float64(i) * 2 * math.Pi / sides)
+		blast[i] = pos.Add(mgl.Vec2{float32(cos), float32(sin)}.Mul(r))
 	}
+
+	aabb := mgl.Vec4{pos.X() - r, pos.Y() - r, pos.X() + r, pos.Y() + r}
+	enemies := enemyRaces(m.race)
+	for _, s := range world.ShipsNear(aabb) {
+		if s == hitShip || (!m.friendlyFire && !hasRace(enemies, s.Race)) {
+			continue
+		}
+		if !CheckAABB(aabb, s.AABB()) {
+			continue
+		}
+		if ok, part, _ := s.hitTest(blast[:]); ok {
+			s.HitPart(part, m.damage)
+		}
+	}
+
+	explosion := world.NewParticle(pos, r, 2*r, ttl, m.color)
+	world.AddObjects(explosion)
 }
 
-func (m *Missile) Draw(renderer *Renderer) {
+func (m *Missile) Draw(renderer Renderer) {
 	const huge = 25
 
 	sides := 10
 	if Max(m.size.Elem()) > huge {
 		sides *= 2
 	}
-	renderer.DrawPoly(m.pos, m.size, sides, m.color, NeonGroup)
+	speed := m.velocity.Len()
+	if m.size.X() == m.size.Y() || speed < 1e-3 {
+		renderer.DrawPoly(m.pos, m.size, sides, m.color, NeonGroup)
+		return
+	}
+
+	// stretched missiles point where they fly
+	rotation := mgl.Rotate2D(angleBetween(mgl.Vec2{1, 0}, m.velocity))
+	points := mgl.Circle(m.size.X()/2, m.size.Y()/2, sides)
+	for i, p := range points {
+		points[i] = m.pos.Add(rotation.Mul2x1(p))
+	}
+	renderer.Draw(points, m.color, NeonGroup)
 }
 
 func (m *Missile) AABB(movement mgl.Vec2) mgl.Vec4 {
@@ -85,6 +220,31 @@ func (m *Missile) AABB(movement mgl.Vec2) mgl.Vec4 {
 	return aabb
 }
 
+// sweep returns a rectangle covering the missile on its way to newPos.
+func (m *Missile) sweep(newPos mgl.Vec2) [4]mgl.Vec2 {
+	dir := mgl.Vec2{1, 0}
+	move := newPos.Sub(m.pos)
+	if length := move.Len(); length > 1e-6 {
+		dir = move.Mul(1 / length)
+	}
+	normal := mgl.Vec2{-dir.Y(), dir.X()}
+
+	halfX, halfY := m.size.X()/2, m.size.Y()/2
+	along := mgl.Abs(dir.X())*halfX + mgl.Abs(dir.Y())*halfY
+	across := mgl.Abs(normal.X())*halfX + mgl.Abs(normal.Y())*halfY
+
+	back := m.pos.Sub(dir.Mul(along))
+	front := newPos.Add(dir.Mul(along))
+	side := normal.Mul(across)
+
+	return [4]mgl.Vec2{
+		back.Sub(side),
+		front.Sub(side),
+		front.Add(side),
+		back.Add(side),
+	}
+}
+
 func (m *Missile) intersection(newPos, p1, p2 mgl.Vec2) (bool, mgl.Vec2) {
 	ok, point := SegmentIntersection(newPos, m.pos, p1, p2)
 	if ok {
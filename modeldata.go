@@ -0,0 +1,587 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/lucasb-eyer/go-colorful"
+)
+
+// Model files are JSON objects of ship models by name. Colors are written
+// as "#rrggbb" or "#rrggbbaa", the rest mirrors ShipModel. SVG is a path
+// relative to the model file, the shape and missing Color1 and Color2 come
+// from it.
+type shipModelData struct {
+	Size         mgl.Vec2
+	Speed        float32
+	Hp           int
+	Color1       string
+	Color2       string
+	DmgColor     string
+	BlowupFactor float32
+	Guns         []gunModelData
+	Engines      []engineModelData
+	Outline      []mgl.Vec2
+	Holes        [][]mgl.Vec2
+	Layers       []hullLayerData
+	Parts        []partModelData
+	SVG          string
+}
+
+type hullLayerData struct {
+	Outline []mgl.Vec2
+	Holes   [][]mgl.Vec2
+	Color   string // empty is Color2
+}
+
+type partModelData struct {
+	Name         string
+	Parent       string
+	Outline      []mgl.Vec2
+	Hp           int
+	Color        string // empty is Color2
+	Guns         []int  // indices from 0
+	Engines      []int
+	BlowupFactor float32
+}
+
+// gunModelData has angles in degrees.
+type gunModelData struct {
+	Pos        mgl.Vec2
+	Angle      float32
+	Arc        float32
+	TurnRate   float32
+	Rate       float32
+	Speed      float32
+	Size       mgl.Vec2
+	Color      string
+	Sound      string
+	SoundGain  float32
+	SoundPitch float32
+	Emitter    *emitterData
+
+	Damage       int
+	Pierce       int
+	Blast        float32
+	FriendlyFire bool
+	Homing       *homingData
+}
+
+// homingData mirrors Homing with angles in degrees.
+type homingData struct {
+	Cone       float32
+	TurnRate   float32
+	Fuel       float32
+	Smoke      float32
+	SmokeColor string
+}
+
+// emitterData mirrors Emitter with angles in degrees.
+type emitterData struct {
+	Pattern   string
+	Aim       bool
+	Count     int
+	Spread    float32
+	Spin      float32
+	SpeedStep float32
+	Accel     float32
+	EndSpeed  float32
+}
+
+type engineModelData struct {
+	Pos          mgl.Vec2
+	Size         float32
+	Rate         float32
+	ParticleSize mgl.Vec2
+	Color        string
+	TTL          float32
+	MinVelocity  float32
+}
+
+type ModelWatcher struct {
+	path     string
+	modTimes map[string]time.Time
+}
+
+func LoadModels(path string) (map[string]ShipModel, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var data map[string]shipModelData
+	decoder := json.NewDecoder(f)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&data); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	dir := filepath.Dir(path)
+	models := make(map[string]ShipModel, len(data))
+	for name, d := range data {
+		model, err := d.model(dir)
+		if err == nil {
+			err = ValidateModel(&model)
+		}
+		if err == nil {
+			err = model.Triangulate()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: model %q: %v", path, name, err)
+		}
+		models[name] = model
+	}
+	return models, nil
+}
+
+// ApplyModels replaces ship models in place, so ships that are already in
+// the world pick up the changes on their next update.
+func ApplyModels(models map[string]ShipModel) {
+	for name, model := range models {
+		if m, found := ShipModels[name]; found {
+			*m = model
+		} else {
+			model := model
+			ShipModels[name] = &model
+		}
+	}
+}
+
+func SaveModels(path string) error {
+	names := make([]string, 0, len(ShipModels))
+	for name := range ShipModels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	data := make(map[string]shipModelData, len(names))
+	for _, name := range names {
+		data[name] = newShipModelData(ShipModels[name])
+	}
+
+	out, err := json.MarshalIndent(data, "", "    ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(out, '\n'), 0644)
+}
+
+func ValidateModel(model *ShipModel) error {
+	switch {
+	case model.Size.X() <= 0 || model.Size.Y() <= 0:
+		return errors.New("size must be positive")
+	case model.Speed < 0:
+		return errors.New("speed can't be negative")
+	case model.Hp <= 0:
+		return errors.New("hp must be positive")
+	case model.BlowupFactor <= 0:
+		return errors.New("blowup factor must be positive")
+	case len(model.Outline) < 3:
+		return errors.New("outline needs at least 3 points")
+	}
+
+	for i, gun := range model.Guns {
+		if gun.Rate <= 0 {
+			return fmt.Errorf("gun %d: rate must be positive", i+1)
+		}
+		if gun.Size.X() <= 0 || gun.Size.Y() <= 0 {
+			return fmt.Errorf("gun %d: size must be positive", i+1)
+		}
+		if gun.Arc < 0 || gun.Arc > math.Pi {
+			return fmt.Errorf("gun %d: arc must be 0 to 180 degrees", i+1)
+		}
+		if gun.TurnRate < 0 {
+			return fmt.Errorf("gun %d: negative turn rate", i+1)
+		}
+		if gun.Damage < 0 || gun.Pierce < 0 || gun.Blast < 0 {
+			return fmt.Errorf("gun %d: negative damage, pierce or blast",
+				i+1)
+		}
+		if err := validateEmitter(gun.Emitter); err != nil {
+			return fmt.Errorf("gun %d: %v", i+1, err)
+		}
+		if err := validateHoming(gun.Homing); err != nil {
+			return fmt.Errorf("gun %d: %v", i+1, err)
+		}
+	}
+	for i, engine := range model.Engines {
+		if engine.Rate <= 0 {
+			return fmt.Errorf("engine %d: rate must be positive", i+1)
+		}
+	}
+
+	names := make(map[string]bool)
+	for _, part := range model.Parts {
+		if err := validatePart(model, &part, names); err != nil {
+			return fmt.Errorf("part %q: %v", part.Name, err)
+		}
+		names[part.Name] = true
+	}
+	return nil
+}
+
+// validatePart checks a part, parents have to go before their parts.
+func validatePart(model *ShipModel, part *PartModel,
+	names map[string]bool) error {
+
+	switch {
+	case part.Name == "" || names[part.Name]:
+		return errors.New("parts need unique names")
+	case part.Parent != "" && !names[part.Parent]:
+		return fmt.Errorf("unknown parent %q", part.Parent)
+	case part.Hp <= 0:
+		return errors.New("hp must be positive")
+	case part.BlowupFactor <= 0:
+		return errors.New("blowup factor must be positive")
+	case len(part.Outline) < 3:
+		return errors.New("outline needs at least 3 points")
+	}
+
+	for _, gun := range part.Guns {
+		if gun < 0 || gun >= len(model.Guns) {
+			return fmt.Errorf("no gun %d", gun)
+		}
+	}
+	for _, engine := range part.Engines {
+		if engine < 0 || engine >= len(model.Engines) {
+			return fmt.Errorf("no engine %d", engine)
+		}
+	}
+	return nil
+}
+
+// NewModelWatcher has to be made after models are loaded from path, so
+// it knows SVG files to watch as well.
+func NewModelWatcher(path string) *ModelWatcher {
+	w := &ModelWatcher{path: path, modTimes: make(map[string]time.Time)}
+	w.changed()
+	return w
+}
+
+// Reload loads and applies models if the file or any SVG file used by
+// models was changed since the last call. A broken file is reported,
+// previous models stay in use.
+func (w *ModelWatcher) Reload() (bool, error) {
+	if !w.changed() {
+		return false, nil
+	}
+
+	models, err := LoadModels(w.path)
+	if err != nil {
+		return false, err
+	}
+	ApplyModels(models)
+	w.changed() // pick up new SVG files
+	return true, nil
+}
+
+func (w *ModelWatcher) changed() bool {
+	files := []string{w.path}
+	for _, model := range ShipModels {
+		if model.Source != "" {
+			files = append(files, model.Source)
+		}
+	}
+
+	changed := false
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			continue // may be in the middle of saving
+		}
+		if !info.ModTime().Equal(w.modTimes[file]) {
+			w.modTimes[file] = info.ModTime()
+			changed = true
+		}
+	}
+	return changed
+}
+
+func (d *shipModelData) model(dir string) (ShipModel, error) {
+	var err error
+	color := func(hex string) mgl.Vec4 {
+		c, e := ParseColor(hex)
+		if e != nil && err == nil {
+			err = e
+		}
+		return c
+	}
+	hullColor := func(hex string) mgl.Vec4 {
+		if hex == "" && d.SVG != "" {
+			return mgl.Vec4{} // taken from the drawing
+		}
+		return color(hex)
+	}
+
+	model := ShipModel{
+		Size:         d.Size,
+		Speed:        d.Speed,
+		Hp:           d.Hp,
+		Color1:       hullColor(d.Color1),
+		Color2:       hullColor(d.Color2),
+		DmgColor:     color(d.DmgColor),
+		BlowupFactor: d.BlowupFactor,
+		Outline:      d.Outline,
+		Holes:        d.Holes,
+	}
+	for _, l := range d.Layers {
+		layer := HullLayer{Outline: l.Outline, Holes: l.Holes}
+		if l.Color != "" {
+			layer.Color = color(l.Color)
+		}
+		model.Layers = append(model.Layers, layer)
+	}
+	for _, p := range d.Parts {
+		part := PartModel{
+			Name:         p.Name,
+			Parent:       p.Parent,
+			Outline:      p.Outline,
+			Hp:           p.Hp,
+			Guns:         p.Guns,
+			Engines:      p.Engines,
+			BlowupFactor: p.BlowupFactor,
+		}
+		if p.Color != "" {
+			part.Color = color(p.Color)
+		}
+		model.Parts = append(model.Parts, part)
+	}
+	for _, g := range d.Guns {
+		model.Guns = append(model.Guns, GunModel{
+			Pos:        g.Pos,
+			Angle:      mgl.DegToRad(g.Angle),
+			Arc:        mgl.DegToRad(g.Arc),
+			TurnRate:   mgl.DegToRad(g.TurnRate),
+			Rate:       g.Rate,
+			Speed:      g.Speed,
+			Size:       g.Size,
+			Color:      color(g.Color),
+			Sound:      g.Sound,
+			SoundGain:  g.SoundGain,
+			SoundPitch: g.SoundPitch,
+			Emitter:    g.Emitter.emitter(),
+
+			Damage:       g.Damage,
+			Pierce:       g.Pierce,
+			Blast:        g.Blast,
+			FriendlyFire: g.FriendlyFire,
+			Homing:       g.Homing.homing(color),
+		})
+	}
+	for _, e := range d.Engines {
+		model.Engines = append(model.Engines, EngineModel{
+			Pos:          e.Pos,
+			Size:         e.Size,
+			Rate:         e.Rate,
+			ParticleSize: e.ParticleSize,
+			Color:        color(e.Color),
+			TTL:          e.TTL,
+			MinVelocity:  e.MinVelocity,
+		})
+	}
+
+	if err == nil && d.SVG != "" {
+		err = model.ImportSVG(filepath.Join(dir, d.SVG))
+	}
+	return model, err
+}
+
+func newShipModelData(model *ShipModel) shipModelData {
+	d := shipModelData{
+		Size:         model.Size,
+		Speed:        model.Speed,
+		Hp:           model.Hp,
+		Color1:       FormatColor(model.Color1),
+		Color2:       FormatColor(model.Color2),
+		DmgColor:     FormatColor(model.DmgColor),
+		BlowupFactor: model.BlowupFactor,
+		Outline:      model.Outline,
+		Holes:        model.Holes,
+	}
+	for _, l := range model.Layers {
+		layer := hullLayerData{Outline: l.Outline, Holes: l.Holes}
+		if l.Color != (mgl.Vec4{}) {
+			layer.Color = FormatColor(l.Color)
+		}
+		d.Layers = append(d.Layers, layer)
+	}
+	for _, p := range model.Parts {
+		part := partModelData{
+			Name:         p.Name,
+			Parent:       p.Parent,
+			Outline:      p.Outline,
+			Hp:           p.Hp,
+			Guns:         p.Guns,
+			Engines:      p.Engines,
+			BlowupFactor: p.BlowupFactor,
+		}
+		if p.Color != (mgl.Vec4{}) {
+			part.Color = FormatColor(p.Color)
+		}
+		d.Parts = append(d.Parts, part)
+	}
+	for _, g := range model.Guns {
+		d.Guns = append(d.Guns, gunModelData{
+			Pos:        g.Pos,
+			Angle:      mgl.RadToDeg(g.Angle),
+			Arc:        mgl.RadToDeg(g.Arc),
+			TurnRate:   mgl.RadToDeg(g.TurnRate),
+			Rate:       g.Rate,
+			Speed:      g.Speed,
+			Size:       g.Size,
+			Color:      FormatColor(g.Color),
+			Sound:      g.Sound,
+			SoundGain:  g.SoundGain,
+			SoundPitch: g.SoundPitch,
+			Emitter:    newEmitterData(g.Emitter),
+
+			Damage:       g.Damage,
+			Pierce:       g.Pierce,
+			Blast:        g.Blast,
+			FriendlyFire: g.FriendlyFire,
+			Homing:       newHomingData(g.Homing),
+		})
+	}
+	for _, e := range model.Engines {
+		d.Engines = append(d.Engines, engineModelData{
+			Pos:          e.Pos,
+			Size:         e.Size,
+			Rate:         e.Rate,
+			ParticleSize: e.ParticleSize,
+			Color:        FormatColor(e.Color),
+			TTL:          e.TTL,
+			MinVelocity:  e.MinVelocity,
+		})
+	}
+	return d
+}
+
+func ParseColor(hex string) (mgl.Vec4, error) {
+	alpha := float32(1)
+	if len(hex) == 9 {
+		a, err := strconv.ParseUint(hex[7:], 16, 8)
+		if err != nil {
+			return mgl.Vec4{}, fmt.Errorf("bad color %q", hex)
+		}
+		alpha = float32(a) / 255
+		hex = hex[:7]
+	}
+
+	c, err := colorful.Hex(hex)
+	if err != nil {
+		return mgl.Vec4{}, fmt.Errorf("bad color %q", hex)
+	}
+	return mgl.Vec4{float32(c.R), float32(c.G), float32(c.B), alpha}, nil
+}
+
+func FormatColor(color mgl.Vec4) string {
+	r, g, b, a := color.Elem()
+	c := colorful.Color{R: float64(r), G: float64(g), B: float64(b)}
+	if a >= 1 {
+		return c.Hex()
+	}
+	return fmt.Sprintf("%s%02x", c.Hex(), uint8(a*255+0.5))
+}
+
+func validateEmitter(e *Emitter) error {
+	switch {
+	case e == nil:
+		return nil
+	case !emitterPatterns[e.Pattern]:
+		return fmt.Errorf("unknown emitter pattern %q", e.Pattern)
+	case e.Count < 0:
+		return errors.New("negative emitter count")
+	case e.Accel != 0 && e.EndSpeed == 0:
+		return errors.New("bullets would stop, set end speed")
+	}
+	return nil
+}
+
+func (d *emitterData) emitter() *Emitter {
+	if d == nil {
+		return nil
+	}
+	return &Emitter{
+		Pattern:   d.Pattern,
+		Aim:       d.Aim,
+		Count:     d.Count,
+		Spread:    mgl.DegToRad(d.Spread),
+		Spin:      mgl.DegToRad(d.Spin),
+		SpeedStep: d.SpeedStep,
+		Accel:     d.Accel,
+		EndSpeed:  d.EndSpeed,
+	}
+}
+
+func newEmitterData(e *Emitter) *emitterData {
+	if e == nil {
+		return nil
+	}
+	return &emitterData{
+		Pattern:   e.Pattern,
+		Aim:       e.Aim,
+		Count:     e.Count,
+		Spread:    mgl.RadToDeg(e.Spread),
+		Spin:      mgl.RadToDeg(e.Spin),
+		SpeedStep: e.SpeedStep,
+		Accel:     e.Accel,
+		EndSpeed:  e.EndSpeed,
+	}
+}
+
+func validateHoming(h *Homing) error {
+	switch {
+	case h == nil:
+		return nil
+	case h.Cone <= 0 || h.Cone > math.Pi:
+		return errors.New("homing cone must be 0 to 180 degrees")
+	case h.TurnRate <= 0:
+		return errors.New("homing turn rate must be positive")
+	case h.Fuel <= 0:
+		return errors.New("homing fuel must be positive")
+	case h.Smoke < 0:
+		return errors.New("negative homing smoke rate")
+	}
+	return nil
+}
+
+func (d *homingData) homing(color func(string) mgl.Vec4) *Homing {
+	if d == nil {
+		return nil
+	}
+	h := &Homing{
+		Cone:     mgl.DegToRad(d.Cone),
+		TurnRate: mgl.DegToRad(d.TurnRate),
+		Fuel:     d.Fuel,
+		Smoke:    d.Smoke,
+	}
+	if d.Smoke > 0 {
+		h.SmokeColor = color(d.SmokeColor)
+	}
+	return h
+}
+
+func newHomingData(h *Homing) *homingData {
+	if h == nil {
+		return nil
+	}
+	d := &homingData{
+		Cone:     mgl.RadToDeg(h.Cone),
+		TurnRate: mgl.RadToDeg(h.TurnRate),
+		Fuel:     h.Fuel,
+		Smoke:    h.Smoke,
+	}
+	if h.Smoke > 0 {
+		d.SmokeColor = FormatColor(h.SmokeColor)
+	}
+	return d
+}
@@ -1,6 +1,10 @@
 package main
 
-import mgl "github.com/go-gl/mathgl/mgl32"
+import (
+	"fmt"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+)
 
 type ShipModel struct {
 	Size         mgl.Vec2
@@ -12,11 +16,49 @@ type ShipModel struct {
 	BlowupFactor float32
 	Guns         []GunModel
 	Engines      []EngineModel
-	Hull         []mgl.Vec2
+	Outline      []mgl.Vec2   // closed polygon, collisions use it
+	Holes        [][]mgl.Vec2 // cut out of the outline when drawn
+	Layers       []HullLayer  // drawn over the hull instead of Color2 layer
+	Parts        []PartModel  // the ship hp is the core under them
+	Source       string       // SVG file the shape was imported from
+
+	Hull  []mgl.Vec2 // triangles to draw, made by Triangulate
+	Solid []mgl.Vec2 // triangles of the outline without holes
+}
+
+type HullLayer struct {
+	Outline []mgl.Vec2
+	Holes   [][]mgl.Vec2
+	Color   mgl.Vec4 // zero color is Color2 of the model
+
+	Hull []mgl.Vec2
+}
+
+// PartModel is a destructible piece of a ship like a turret or an armour
+// plate. Missiles hit living parts before the ship under them. A destroyed
+// part blows up, disables its guns and engines and takes the parts
+// attached to it along.
+type PartModel struct {
+	Name         string
+	Parent       string // part it is attached to, empty is the core
+	Outline      []mgl.Vec2
+	Hp           int
+	Color        mgl.Vec4 // zero color is Color2 of the model
+	Guns         []int    // indices of the model guns
+	Engines      []int
+	BlowupFactor float32
+
+	Hull []mgl.Vec2
 }
 
+// GunModel fires along the ship turned by Angle. Tracking guns turn to
+// the nearest enemy at TurnRate, up to Arc either way or all around if Arc
+// is 0. Angles are in radians.
 type GunModel struct {
 	Pos        mgl.Vec2
+	Angle      float32
+	Arc        float32
+	TurnRate   float32 // radians per second, 0 is a fixed gun
 	Rate       float32
 	Speed      float32
 	Size       mgl.Vec2
@@ -24,6 +66,13 @@ type GunModel struct {
 	Sound      string
 	SoundGain  float32
 	SoundPitch float32
+	Emitter    *Emitter
+
+	Damage       int     // per hit, 0 is 1
+	Pierce       int     // ships to pass through before the missile dies
+	Blast        float32 // radius of the area damage, 0 is none
+	FriendlyFire bool    // the blast damages allies too
+	Homing       *Homing
 }
 
 type EngineModel struct {
@@ -92,28 +141,16 @@ var PlayerModel = ShipModel{
 			MinVelocity:  1,
 		},
 	},
-	Hull: []mgl.Vec2{
-		{+0.0, +1.0}, //a
-		{-0.2, -0.2}, //e
-		{+0.2, -0.2}, //f
+	Outline: []mgl.Vec2{
 		{+0.0, +1.0}, //a
 		{-0.4, +0.8}, //b
-		{-0.2, -0.2}, //e
-		{+0.0, +1.0}, //a
-		{+0.2, -0.2}, //f
-		{+0.4, +0.8}, //j
-		{-0.4, +0.8}, //b
-		{-1.0, -0.3}, //c
-		{-0.2, -0.2}, //e
-		{+0.4, +0.8}, //j
-		{+0.2, -0.2}, //f
-		{+1.0, -0.3}, //i
 		{-1.0, -0.3}, //c
 		{-0.8, -1.0}, //d
 		{-0.2, -0.2}, //e
 		{+0.2, -0.2}, //f
 		{+0.8, -1.0}, //g
 		{+1.0, -0.3}, //i
+		{+0.4, +0.8}, //j
 	},
 }
 
@@ -136,28 +173,16 @@ var CargoModel = ShipModel{
 			MinVelocity:  0,
 		},
 	},
-	Hull: []mgl.Vec2{
-		{+0.0, +1.0}, //a
-		{-0.4, -0.0}, //e
-		{+0.4, -0.0}, //f
+	Outline: []mgl.Vec2{
 		{+0.0, +1.0}, //a
 		{-0.6, +0.8}, //b
-		{-0.4, -0.0}, //e
-		{+0.0, +1.0}, //a
-		{+0.4, -0.0}, //f
-		{+0.6, +0.8}, //j
-		{-0.6, +0.8}, //b
-		{-1.0, -0.1}, //c
-		{-0.4, -0.0}, //e
-		{+0.6, +0.8}, //j
-		{+0.4, -0.0}, //f
-		{+1.0, -0.1}, //i
 		{-1.0, -0.1}, //c
 		{-0.6, -1.0}, //d
 		{-0.4, -0.0}, //e
 		{+0.4, -0.0}, //f
 		{+0.6, -1.0}, //g
 		{+1.0, -0.1}, //i
+		{+0.6, +0.8}, //j
 	},
 }
 
@@ -170,7 +195,7 @@ var CargoModel2 = ShipModel{
 	DmgColor:     CargoModel.DmgColor,
 	BlowupFactor: 1.3,
 	Engines:      CargoModel.Engines,
-	Hull:         CargoModel.Hull,
+	Outline:      CargoModel.Outline,
 }
 
 var FighterModel = ShipModel{
@@ -200,28 +225,16 @@ var FighterModel = ShipModel{
 			Color: HexColor("#e50c0c", 1),
 		},
 	},
-	Hull: []mgl.Vec2{
-		{+0.0, -1.0}, //a
-		{-0.4, +0.0}, //e
-		{+0.4, +0.0}, //f
-		{+0.0, -1.0}, //a
-		{-0.6, -0.8}, //b
-		{-0.4, +0.0}, //e
+	Outline: []mgl.Vec2{
 		{+0.0, -1.0}, //a
-		{+0.4, +0.0}, //f
-		{+0.6, -0.8}, //j
-		{-0.6, -0.8}, //b
-		{-1.0, +0.1}, //c
-		{-0.4, +0.0}, //e
 		{+0.6, -0.8}, //j
-		{+0.4, +0.0}, //f
 		{+1.0, +0.1}, //i
-		{-1.0, +0.1}, //c
-		{-0.6, +1.0}, //d
-		{-0.4, +0.0}, //e
-		{+0.4, +0.0}, //f
 		{+0.6, +1.0}, //g
-		{+1.0, +0.1}, //i
+		{+0.4, +0.0}, //f
+		{-0.4, +0.0}, //e
+		{-0.6, +1.0}, //d
+		{-1.0, +0.1}, //c
+		{-0.6, -0.8}, //b
 	},
 }
 
@@ -245,7 +258,7 @@ var ShooterModel = ShipModel{
 			SoundPitch: 1,
 		},
 	},
-	Hull: FighterModel.Hull,
+	Outline: FighterModel.Outline,
 }
 
 var PapaModel = ShipModel{
@@ -279,7 +292,85 @@ var PapaModel = ShipModel{
 			MinVelocity:  0,
 		},
 	},
-	Hull: FighterModel.Hull,
+	Outline: FighterModel.Outline,
+}
+
+var ShipModels = map[string]*ShipModel{
+	"player":  &PlayerModel,
+	"cargo":   &CargoModel,
+	"cargo2":  &CargoModel2,
+	"fighter": &FighterModel,
+	"shooter": &ShooterModel,
+	"papa":    &PapaModel,
+}
+
+func init() {
+	for name, model := range ShipModels {
+		if err := model.Triangulate(); err != nil {
+			panic(fmt.Sprintf("ship model %q: %v", name, err))
+		}
+	}
+}
+
+// Triangulate makes Hull and Solid from Outline and Holes, and layer and
+// part hulls. It has to be called again after they are changed.
+func (model *ShipModel) Triangulate() error {
+	hull, err := Triangulate(model.Outline, model.Holes)
+	if err != nil {
+		return err
+	}
+	solid, err := Triangulate(model.Outline, nil)
+	if err != nil {
+		return err
+	}
+	for i := range model.Layers {
+		layer := &model.Layers[i]
+		layer.Hull, err = Triangulate(layer.Outline, layer.Holes)
+		if err != nil {
+			return fmt.Errorf("layer %d: %v", i+1, err)
+		}
+	}
+	for i := range model.Parts {
+		part := &model.Parts[i]
+		part.Hull, err = Triangulate(part.Outline, nil)
+		if err != nil {
+			return fmt.Errorf("part %q: %v", part.Name, err)
+		}
+	}
+	model.Hull, model.Solid = hull, solid
+	return nil
+}
+
+// ImportSVG takes Outline, Holes, Layers and missing colors and size
+// from an SVG file.
+func (model *ShipModel) ImportSVG(path string) error {
+	shape, err := ImportSVG(path)
+	if err != nil {
+		return err
+	}
+
+	model.Outline, model.Holes = shape.Outline, shape.Holes
+	model.Layers = shape.Layers
+	model.Source = path
+	if model.Size == (mgl.Vec2{}) {
+		model.Size = shape.Size
+	}
+	if model.Color1 == (mgl.Vec4{}) {
+		model.Color1 = shape.Color1
+	}
+	if model.Color2 == (mgl.Vec4{}) {
+		model.Color2 = shape.Color2
+	}
+	return nil
+}
+
+func ModelName(model *ShipModel) string {
+	for name, m := range ShipModels {
+		if m == model {
+			return name
+		}
+	}
+	panic("unknown ship model")
 }
 
 var StarModel = []mgl.Vec2{
@@ -16,10 +16,10 @@ type Particle struct {
 	ttl      float32
 }
 
-func NewParticle(pos mgl.Vec2, startSize, endSize, lifetime float32,
-	color mgl.Vec4) *Particle {
+func (p *Particle) init(pos mgl.Vec2, startSize, endSize, lifetime float32,
+	color mgl.Vec4) {
 
-	return &Particle{
+	*p = Particle{
 		RenderGroup: NeonGroup,
 		pos:         pos,
 		startSize:   startSize,
@@ -38,7 +38,7 @@ func (p *Particle) Update(dt float32) {
 	p.ttl -= dt
 }
 
-func (p *Particle) Draw(renderer *Renderer) {
+func (p *Particle) Draw(renderer Renderer) {
 	size := (p.startSize-p.endSize)*(p.ttl/p.lifetime) + p.endSize
 	renderer.DrawPoly(p.pos, mgl.Vec2{size, size}, 10, p.color, p.RenderGroup)
 }
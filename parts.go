@@ -0,0 +1,153 @@
+package main
+
+import mgl "github.com/go-gl/mathgl/mgl32"
+
+type shipPart struct {
+	hp      int
+	damaged bool
+	wrecked bool       // blown up already
+	hull    []mgl.Vec2 // world space triangles
+}
+
+// hitTest finds where a convex shape hits the ship, living parts go first.
+// The part is -1 for the core.
+func (s *Ship) hitTest(poly []mgl.Vec2) (bool, int, Contact) {
+	for i := range s.parts {
+		if s.parts[i].hp <= 0 {
+			continue
+		}
+		if ok, contact := ConvexHullCollide(poly, s.parts[i].hull); ok {
+			return true, i, contact
+		}
+	}
+	ok, contact := ConvexHullCollide(poly, s.solid)
+	return ok, -1, contact
+}
+
+// HitPart damages a part, or the core if part is -1.
+func (s *Ship) HitPart(part, damage int) {
+	if part < 0 {
+		s.Hit(damage)
+	} else if s.Race != Autopilot && !s.Invulnerable {
+		s.parts[part].hp -= damage
+		s.parts[part].damaged = true
+	}
+}
+
+func (s *Ship) PartHealth(part int) float32 {
+	return float32(s.parts[part].hp) / float32(s.model.Parts[part].Hp)
+}
+
+// updateParts blows up parts destroyed since the last update together
+// with the parts attached to them.
+func (s *Ship) updateParts(world *World) {
+	wrecked := false
+	for i, model := range s.model.Parts {
+		part := &s.parts[i]
+		if part.hp > 0 && s.partDestroyed(model.Parent) {
+			part.hp = 0
+		}
+		if part.hp <= 0 && !part.wrecked {
+			part.wrecked = true
+			wrecked = true
+			s.explodePart(world, i)
+		}
+	}
+	if wrecked {
+		s.updateDisabled()
+	}
+}
+
+func (s *Ship) partDestroyed(name string) bool {
+	for i, model := range s.model.Parts {
+		if name != "" && model.Name == name {
+			return s.parts[i].hp <= 0
+		}
+	}
+	return false
+}
+
+// updateDisabled turns off guns and engines of wrecked parts.
+func (s *Ship) updateDisabled() {
+	s.gunOff = make([]bool, len(s.model.Guns))
+	s.engineOff = make([]bool, len(s.model.Engines))
+	for i, model := range s.model.Parts {
+		if !s.parts[i].wrecked {
+			continue
+		}
+		for _, gun := range model.Guns {
+			s.gunOff[gun] = true
+		}
+		for _, engine := range model.Engines {
+			s.engineOff[engine] = true
+		}
+	}
+}
+
+// syncParts adapts parts to the model, new parts are intact.
+func (s *Ship) syncParts() {
+	models := s.model.Parts
+	if len(s.parts) != len(models) {
+		parts := make([]shipPart, len(models))
+		copy(parts, s.parts)
+		for i := len(s.parts); i < len(models); i++ {
+			parts[i].hp = models[i].Hp
+		}
+		s.parts = parts
+	}
+	for i := range s.parts {
+		if s.parts[i].hp > models[i].Hp {
+			s.parts[i].hp = models[i].Hp
+		}
+	}
+	s.updateDisabled()
+}
+
+func (s *Ship) updatePartHulls() {
+	for i, model := range s.model.Parts {
+		part := &s.parts[i]
+		if len(part.hull) != len(model.Hull) {
+			part.hull = make([]mgl.Vec2, len(model.Hull))
+		}
+		for j, p := range model.Hull {
+			part.hull[j] = s.transformPoint(p)
+		}
+	}
+}
+
+func (s *Ship) drawParts(renderer Renderer) {
+	for i, model := range s.model.Parts {
+		part := &s.parts[i]
+		if part.hp <= 0 {
+			continue
+		}
+		color := model.Color
+		if color == (mgl.Vec4{}) {
+			color = s.model.Color2
+		}
+		if part.damaged {
+			part.damaged = false
+			color = WhiteColor
+		}
+		renderer.Draw(part.hull, color, PlainGroup)
+	}
+}
+
+func (s *Ship) explodePart(world *World, i int) {
+	model := s.model.Parts[i]
+	if len(model.Outline) == 0 {
+		return
+	}
+
+	var center mgl.Vec2
+	min, max := model.Outline[0], model.Outline[0]
+	for _, p := range model.Outline {
+		center = center.Add(p.Mul(1 / float32(len(model.Outline))))
+		min, max = MinVec2(min, p), MaxVec2(max, p)
+	}
+	extent := max.Sub(min)
+	size := Max(extent.X()*s.model.Size.X(), extent.Y()*s.model.Size.Y()) / 2
+
+	s.explode(world, s.transformPoint(center), size, model.BlowupFactor)
+	world.PlaySound("boom", 0.6, 1/model.BlowupFactor)
+}
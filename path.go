@@ -0,0 +1,248 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+)
+
+const pathSegmentSamples = 16
+
+// PathDef is a spline in fractions of the world size. Catmull-Rom splines
+// pass through all points, bezier ones are chains of cubic curves: a point,
+// two control points, a point and so on. Speed and Fire keys use fractions
+// of the path length.
+type PathDef struct {
+	Type   string // catmull-rom (default) or bezier
+	Points []mgl.Vec2
+	Loop   bool
+	Speed  [][2]float32 // [at, speed] keys, speed is a fraction of model speed
+	Fire   [][2]float32 // [from, to] ranges to fire at
+}
+
+// Path is a PathDef sampled to a polyline in world pixels.
+type Path struct {
+	Def     PathDef
+	points  []mgl.Vec2
+	lengths []float32 // path length at every point
+}
+
+// PathFollower is a pilot that flies a path starting from the ship
+// position at the first update.
+type PathFollower struct {
+	Path   *Path
+	Speed  float32 // used when the path has no speed keys
+	Mirror bool    // flip the path vertically
+
+	start   mgl.Vec2
+	dist    float32
+	started bool
+}
+
+func (def *PathDef) Validate() error {
+	switch def.Type {
+	case "":
+		def.Type = "catmull-rom"
+	case "catmull-rom":
+	case "bezier":
+		if len(def.Points)%3 != 1 {
+			return errors.New("bezier path needs 3n+1 points")
+		}
+	default:
+		return fmt.Errorf("unknown path type %q", def.Type)
+	}
+	if len(def.Points) < 2 {
+		return errors.New("path needs at least 2 points")
+	}
+	coincide := true
+	for _, p := range def.Points[1:] {
+		coincide = coincide && p == def.Points[0]
+	}
+	if coincide {
+		return errors.New("path has zero length")
+	}
+
+	for _, keys := range [][][2]float32{def.Speed, def.Fire} {
+		for _, key := range keys {
+			if key[0] < 0 || key[0] > 1 || key[1] < 0 {
+				return fmt.Errorf("bad path key %v", key)
+			}
+		}
+	}
+	for _, fire := range def.Fire {
+		if fire[1] < fire[0] || fire[1] > 1 {
+			return fmt.Errorf("bad fire range %v", fire)
+		}
+	}
+	sort.SliceStable(def.Speed, func(i, j int) bool {
+		return def.Speed[i][0] < def.Speed[j][0]
+	})
+	return nil
+}
+
+func NewPath(def PathDef, size mgl.Vec2) *Path {
+	points := make([]mgl.Vec2, len(def.Points))
+	for i, p := range def.Points {
+		points[i] = mgl.Vec2{p.X() * size.X(), p.Y() * size.Y()}
+	}
+
+	path := &Path{Def: def}
+	if def.Type == "bezier" {
+		path.sampleBezier(points)
+	} else {
+		path.sampleCatmullRom(points)
+	}
+
+	path.lengths = make([]float32, len(path.points))
+	for i := 1; i < len(path.points); i++ {
+		segment := path.points[i].Sub(path.points[i-1]).Len()
+		path.lengths[i] = path.lengths[i-1] + segment
+	}
+	return path
+}
+
+func (path *Path) sampleBezier(points []mgl.Vec2) {
+	if path.Def.Loop && !points[0].ApproxEqual(points[len(points)-1]) {
+		// close the loop with a straight curve
+		first, last := points[0], points[len(points)-1]
+		points = append(points, lerpVec2(last, first, 1.0/3),
+			lerpVec2(last, first, 2.0/3), first)
+	}
+
+	path.points = append(path.points, points[0])
+	for i := 0; i+3 < len(points); i += 3 {
+		for j := 1; j <= pathSegmentSamples; j++ {
+			t := float32(j) / pathSegmentSamples
+			path.points = append(path.points, mgl.CubicBezierCurve2D(t,
+				points[i], points[i+1], points[i+2], points[i+3]))
+		}
+	}
+}
+
+func (path *Path) sampleCatmullRom(points []mgl.Vec2) {
+	n := len(points)
+	at := func(i int) mgl.Vec2 {
+		if path.Def.Loop {
+			return points[(i%n+n)%n]
+		}
+		return points[int(mgl.Clamp(float32(i), 0, float32(n-1)))]
+	}
+
+	segments := n - 1
+	if path.Def.Loop {
+		segments = n
+	}
+
+	path.points = append(path.points, points[0])
+	for i := 0; i < segments; i++ {
+		p0, p1, p2, p3 := at(i-1), at(i), at(i+1), at(i+2)
+		for j := 1; j <= pathSegmentSamples; j++ {
+			t := float32(j) / pathSegmentSamples
+			path.points = append(path.points, catmullRom(p0, p1, p2, p3, t))
+		}
+	}
+}
+
+func (path *Path) Length() float32 {
+	return path.lengths[len(path.lengths)-1]
+}
+
+// At returns the point at dist along the path, past the end it goes on
+// along the last segment.
+func (path *Path) At(dist float32) mgl.Vec2 {
+	last := len(path.points) - 1
+	i := sort.Search(last, func(i int) bool {
+		return path.lengths[i+1] >= dist
+	})
+	if i >= last {
+		i = last - 1
+	}
+
+	from, to := path.lengths[i], path.lengths[i+1]
+	if to-from < 1e-6 {
+		return path.points[i+1]
+	}
+	return lerpVec2(path.points[i], path.points[i+1], (dist-from)/(to-from))
+}
+
+func (path *Path) SpeedAt(t, speed float32) float32 {
+	keys := path.Def.Speed
+	switch {
+	case len(keys) == 0:
+		return speed
+	case t <= keys[0][0]:
+		return keys[0][1]
+	}
+
+	for i := 1; i < len(keys); i++ {
+		if t <= keys[i][0] {
+			k := (t - keys[i-1][0]) / Max(keys[i][0]-keys[i-1][0], 1e-6)
+			return keys[i-1][1] + (keys[i][1]-keys[i-1][1])*k
+		}
+	}
+	return keys[len(keys)-1][1]
+}
+
+func (path *Path) FireAt(t float32) bool {
+	for _, fire := range path.Def.Fire {
+		if fire[0] <= t && t <= fire[1] {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *PathFollower) Update(dt float32, ship *Ship, world *World) {
+	if !f.started {
+		f.started = true
+		f.start = ship.Pos
+	}
+	if dt <= 0 {
+		return
+	}
+
+	length := f.Path.Length()
+	if length <= 0 {
+		return // nowhere to fly
+	}
+	t := Min(f.dist/length, 1)
+	f.dist += f.Path.SpeedAt(t, f.Speed) * ship.model.Speed * dt
+	if f.Path.Def.Loop {
+		for f.dist >= length {
+			f.dist -= length
+		}
+	}
+
+	move := f.position(f.dist).Sub(ship.Pos)
+	ship.Control(move.Mul(1/(ship.model.Speed*dt)), f.Path.FireAt(t))
+
+	bounds := mgl.Vec4{0, 0, world.Size.X(), world.Size.Y()}
+	if f.dist > length && !CheckAABB(ship.AABB(), bounds) {
+		ship.IsDead = true // the path is over and the ship is gone
+	}
+}
+
+func (f *PathFollower) position(dist float32) mgl.Vec2 {
+	origin := f.Path.At(0)
+	offset := f.Path.At(dist).Sub(origin)
+	if f.Mirror {
+		offset[1] = -offset[1]
+	}
+	return f.start.Add(offset)
+}
+
+func catmullRom(p0, p1, p2, p3 mgl.Vec2, t float32) mgl.Vec2 {
+	t2 := t * t
+	t3 := t2 * t
+	a := p1.Mul(2)
+	b := p2.Sub(p0).Mul(t)
+	c := p0.Mul(2).Sub(p1.Mul(5)).Add(p2.Mul(4)).Sub(p3).Mul(t2)
+	d := p1.Mul(3).Sub(p0).Sub(p2.Mul(3)).Add(p3).Mul(t3)
+	return a.Add(b).Add(c).Add(d).Mul(0.5)
+}
+
+func lerpVec2(a, b mgl.Vec2, t float32) mgl.Vec2 {
+	return a.Add(b.Sub(a).Mul(t))
+}
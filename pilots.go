@@ -2,10 +2,20 @@ package main
 
 import mgl "github.com/go-gl/mathgl/mgl32"
 
-type RoundShooter struct {
-	IsDead bool
+// Pilot controls a ship. World updates pilots of its ships before moving
+// them, ships without a pilot keep their last controls.
+type Pilot interface {
+	Update(dt float32, ship *Ship, world *World)
+}
+
+// Advancer flies to the left, stops at StopX and opens fire there.
+type Advancer struct {
+	Speed float32 // fraction of the model speed
+	StopX float32 // fraction of the world width, 0 is never
+	Fire  bool    // fire on the way too
+}
 
-	ship     *Ship
+type RoundShooter struct {
 	endPosX  float32
 	angleMin float32
 	angleMax float32
@@ -13,9 +23,26 @@ type RoundShooter struct {
 	t        float32
 }
 
-func NewRoundShooter(ship *Ship, endPosX, angleMin, angleMax float32) *RoundShooter {
+// SteeringPilot moves a ship with steering behaviours and optionally aims
+// it at the player.
+type SteeringPilot struct {
+	Move Steering
+	Aim  *AimAtPlayer
+	Fire bool // fire all the time, when there is nothing to aim with
+}
+
+func (a *Advancer) Update(dt float32, ship *Ship, world *World) {
+	if a.StopX > 0 && ship.Pos.X() <= world.Size.X()*a.StopX {
+		ship.Control(mgl.Vec2{}, true)
+	} else {
+		ship.Control(mgl.Vec2{-a.Speed, 0}, a.Fire)
+	}
+}
+
+func NewRoundShooter(ship *Ship, endPosX, angleMin,
+	angleMax float32) *RoundShooter {
+
 	rs := &RoundShooter{
-		ship:     ship,
 		endPosX:  endPosX,
 		angleMin: angleMin,
 		angleMax: angleMax,
@@ -24,31 +51,33 @@ func NewRoundShooter(ship *Ship, endPosX, angleMin, angleMax float32) *RoundShoo
 	return rs
 }
 
-func (rs *RoundShooter) Update(dt float32, world *World) {
+func (rs *RoundShooter) Update(dt float32, ship *Ship, world *World) {
 	const speed = 0.2
 	const rSpeed = 0.5
 
-	if rs.ship.IsDead {
-		rs.IsDead = true
-		return
-	}
-
-	if rs.ship.Pos.X() > rs.endPosX*world.Size.X() {
-		rs.ship.Control(mgl.Vec2{-speed, 0}, false)
+	if ship.Pos.X() > rs.endPosX*world.Size.X() {
+		ship.Control(mgl.Vec2{-speed, 0}, false)
 	} else {
-		rs.ship.Control(mgl.Vec2{0, 0}, true)
+		ship.Control(mgl.Vec2{0, 0}, true)
 
 		rs.t += rSpeed * dt
 		for rs.t > 1 {
 			rs.t -= 2
 		}
 		angle := rs.angleMin + mgl.Abs(rs.t)*(rs.angleMax-rs.angleMin)
-		rs.ship.Dir = mgl.Rotate2D(angle).Mul2x1(rs.dir).Normalize()
+		ship.Dir = mgl.Rotate2D(angle).Mul2x1(rs.dir).Normalize()
 	}
 }
 
-func (rs *RoundShooter) Die() {
-	for rs.ship.Health() > 0 {
-		rs.ship.Hit()
+func (p *SteeringPilot) Update(dt float32, ship *Ship, world *World) {
+	var thrust mgl.Vec2
+	if p.Move != nil {
+		thrust = p.Move.Steer(dt, ship, world)
+	}
+
+	fire := p.Fire
+	if p.Aim != nil {
+		fire = p.Aim.Aim(dt, ship, world)
 	}
+	ship.Control(thrust, fire)
 }
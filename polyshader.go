@@ -1,3 +1,6 @@
+//go:build !headless
+// +build !headless
+
 package main
 
 import (
@@ -18,8 +21,6 @@ type PolyShader struct {
 	vbSize       int
 }
 
-type PolyGroup int
-
 const polyVertexSrc string = `
 #version 150 core
 
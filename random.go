@@ -0,0 +1,31 @@
+package main
+
+import "math/rand"
+
+// RandSource is a splitmix64 generator. Unlike the math/rand default
+// source its whole state is a single number, so it is cheap to save.
+type RandSource struct {
+	State uint64
+}
+
+func NewRand(seed int64) (*rand.Rand, *RandSource) {
+	src := &RandSource{}
+	src.Seed(seed)
+	return rand.New(src), src
+}
+
+func (s *RandSource) Seed(seed int64) {
+	s.State = uint64(seed)
+}
+
+func (s *RandSource) Uint64() uint64 {
+	s.State += 0x9e3779b97f4a7c15
+	z := s.State
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+func (s *RandSource) Int63() int64 {
+	return int64(s.Uint64() >> 1)
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// Replay file is: magic "SHRP", format byte, version string, seed, level,
+// models and snapshot hash strings, steps, runs count and runs of {steps,
+// dir x, dir y, fire}. Integers are varints, strings are length prefixed,
+// dir is float32 bits. Controls rarely change between steps, so they are
+// stored run-length encoded. Format 1 has no hashes.
+const (
+	replayMagic   = "SHRP"
+	replayFormat  = 2
+	replayRunSize = 10 // the least, with a one byte steps varint
+)
+
+type Replay struct {
+	Version string
+	Seed    int64
+	Inputs  ReplayInputs
+
+	runs  []replayRun
+	steps int
+}
+
+// ReplayInputs are content hashes of the files the run started with, empty
+// for the built-in ones.
+type ReplayInputs struct {
+	Level    string
+	Models   string
+	Snapshot string
+}
+
+type replayRun struct {
+	steps    int
+	controls Controls
+}
+
+type ReplayPlayer struct {
+	replay *Replay
+	run    int
+	step   int
+	played int
+}
+
+func NewReplay(seed int64, inputs ReplayInputs) *Replay {
+	return &Replay{
+		Version: Version,
+		Seed:    seed,
+		Inputs:  inputs,
+	}
+}
+
+func HashInputs(level, models, snapshot string) (ReplayInputs, error) {
+	var inputs ReplayInputs
+	var err error
+	if inputs.Level, err = hashFile(level); err != nil {
+		return inputs, err
+	}
+	if inputs.Models, err = hashFile(models); err != nil {
+		return inputs, err
+	}
+	inputs.Snapshot, err = hashFile(snapshot)
+	return inputs, err
+}
+
+// hashFile returns sha256 of the file in hex, nothing for no file.
+func hashFile(path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Check tells which file differs from the ones the replay was recorded
+// with.
+func (in ReplayInputs) Check(recorded ReplayInputs) error {
+	for _, input := range []struct{ flag, have, want string }{
+		{"-level", in.Level, recorded.Level},
+		{"-models", in.Models, recorded.Models},
+		{"-snapshot", in.Snapshot, recorded.Snapshot},
+	} {
+		switch {
+		case input.have == input.want:
+		case input.want == "":
+			return fmt.Errorf("replay was recorded without %s", input.flag)
+		case input.have == "":
+			return fmt.Errorf("replay was recorded with %s", input.flag)
+		default:
+			return fmt.Errorf("replay was recorded with another %s file",
+				input.flag)
+		}
+	}
+	return nil
+}
+
+func (r *Replay) Record(c Controls) {
+	if n := len(r.runs); n > 0 && r.runs[n-1].controls == c {
+		r.runs[n-1].steps += 1
+	} else {
+		r.runs = append(r.runs, replayRun{steps: 1, controls: c})
+	}
+	r.steps += 1
+}
+
+func (r *Replay) Truncate(steps int) {
+	for len(r.runs) > 0 && r.steps > steps {
+		last := &r.runs[len(r.runs)-1]
+		cut := r.steps - steps
+		if cut >= last.steps {
+			r.steps -= last.steps
+			r.runs = r.runs[:len(r.runs)-1]
+		} else {
+			r.steps -= cut
+			last.steps -= cut
+		}
+	}
+}
+
+func (r *Replay) Len() int {
+	return r.steps
+}
+
+func (r *Replay) Player() *ReplayPlayer {
+	return &ReplayPlayer{replay: r}
+}
+
+func (r *Replay) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	buf := make([]byte, binary.MaxVarintLen64)
+	putUvarint := func(x uint64) {
+		w.Write(buf[:binary.PutUvarint(buf, x)])
+	}
+	putString := func(s string) {
+		putUvarint(uint64(len(s)))
+		w.WriteString(s)
+	}
+
+	w.WriteString(replayMagic)
+	w.WriteByte(replayFormat)
+	putString(r.Version)
+	w.Write(buf[:binary.PutVarint(buf, r.Seed)])
+	putString(r.Inputs.Level)
+	putString(r.Inputs.Models)
+	putString(r.Inputs.Snapshot)
+	putUvarint(uint64(r.steps))
+	putUvarint(uint64(len(r.runs)))
+
+	for _, run := range r.runs {
+		putUvarint(uint64(run.steps))
+		binary.LittleEndian.PutUint32(buf, math.Float32bits(run.controls.Dir[0]))
+		binary.LittleEndian.PutUint32(buf[4:], math.Float32bits(run.controls.Dir[1]))
+		w.Write(buf[:8])
+		if run.controls.Fire {
+			w.WriteByte(1)
+		} else {
+			w.WriteByte(0)
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func LoadReplay(path string) (*Replay, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	corrupted := errors.New("corrupted replay file: " + path)
+
+	r := bufio.NewReader(f)
+	header := make([]byte, len(replayMagic)+1)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	if string(header[:len(replayMagic)]) != replayMagic {
+		return nil, errors.New("not a replay file: " + path)
+	}
+	format := header[len(replayMagic)]
+	if format < 1 || format > replayFormat {
+		return nil, fmt.Errorf("unsupported replay format %d: %s",
+			format, path)
+	}
+
+	readString := func() (string, error) {
+		n, err := binary.ReadUvarint(r)
+		if err != nil {
+			return "", err
+		}
+		if n > uint64(info.Size()) {
+			return "", corrupted
+		}
+		data := make([]byte, n)
+		_, err = io.ReadFull(r, data)
+		return string(data), err
+	}
+
+	replay := &Replay{}
+	if replay.Version, err = readString(); err != nil {
+		return nil, err
+	}
+	if replay.Seed, err = binary.ReadVarint(r); err != nil {
+		return nil, err
+	}
+	if format >= 2 {
+		for _, hash := range []*string{&replay.Inputs.Level,
+			&replay.Inputs.Models, &replay.Inputs.Snapshot} {
+
+			if *hash, err = readString(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	steps, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if steps > math.MaxInt {
+		return nil, corrupted
+	}
+	runs, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if runs > uint64(info.Size())/replayRunSize {
+		return nil, corrupted
+	}
+
+	replay.runs = make([]replayRun, 0, runs)
+	data := make([]byte, replayRunSize-1)
+	for i := uint64(0); i < runs; i++ {
+		count, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+		if count > steps-uint64(replay.steps) {
+			return nil, corrupted
+		}
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+
+		run := replayRun{steps: int(count)}
+		run.controls.Dir[0] = math.Float32frombits(binary.LittleEndian.Uint32(data))
+		run.controls.Dir[1] = math.Float32frombits(binary.LittleEndian.Uint32(data[4:]))
+		run.controls.Fire = data[8] != 0
+		replay.runs = append(replay.runs, run)
+		replay.steps += run.steps
+	}
+
+	if replay.steps != int(steps) {
+		return nil, corrupted
+	}
+	return replay, nil
+}
+
+func (p *ReplayPlayer) Next() (Controls, bool) {
+	for p.run < len(p.replay.runs) {
+		run := p.replay.runs[p.run]
+		if p.step < run.steps {
+			p.step += 1
+			p.played += 1
+			return run.controls, true
+		}
+		p.run += 1
+		p.step = 0
+	}
+	return Controls{}, false
+}
+
+func (p *ReplayPlayer) Seek(step int) {
+	p.run, p.step, p.played = 0, 0, 0
+	for p.played < step {
+		if _, ok := p.Next(); !ok {
+			break
+		}
+	}
+}
+
+func (p *ReplayPlayer) Done() bool {
+	return p.played >= p.replay.steps
+}
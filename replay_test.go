@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReplaySaveLoad(t *testing.T) {
+	var controls []Controls
+	for i := 0; i < 500; i++ {
+		c := Controls{Fire: i%50 < 20}
+		c.Dir[0] = float32(i/100%3 - 1)
+		c.Dir[1] = 0.25 * float32(i/30%5-2)
+		controls = append(controls, c)
+	}
+
+	inputs := ReplayInputs{Level: "level", Snapshot: "snapshot"}
+	replay := NewReplay(-42, inputs)
+	for _, c := range controls {
+		replay.Record(c)
+	}
+	path := filepath.Join(t.TempDir(), "replay")
+	if err := replay.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadReplay(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Version != Version || loaded.Seed != -42 ||
+		loaded.Inputs != inputs || loaded.Len() != len(controls) {
+
+		t.Fatalf("got %s %d %+v %d steps", loaded.Version, loaded.Seed,
+			loaded.Inputs, loaded.Len())
+	}
+	player := loaded.Player()
+	for i, want := range controls {
+		if got, ok := player.Next(); !ok || got != want {
+			t.Fatalf("step %d: got %+v, want %+v", i, got, want)
+		}
+	}
+	if !player.Done() {
+		t.Error("player is not done")
+	}
+}
+
+func TestLoadReplayCorrupted(t *testing.T) {
+	replay := NewReplay(1, ReplayInputs{Models: "models"})
+	for i := 0; i < 10; i++ {
+		replay.Record(Controls{Fire: i%2 == 0})
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "replay")
+	if err := replay.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	uvarint := func(x uint64) string {
+		return string(binary.AppendUvarint(nil, x))
+	}
+	huge := uvarint(1 << 62)
+	control := string(make([]byte, 9))
+	header := replayMagic + string(rune(replayFormat))
+	// no version, seed 0, no hashes and 10 steps
+	runs := header + string([]byte{0, 0, 0, 0, 0, 10})
+	files := []string{
+		header + huge,
+		runs + huge,
+		// 20 and -10 steps add up to 10
+		runs + uvarint(2) + uvarint(20) + control + uvarint(1<<64-10) +
+			control,
+	}
+	for n := 0; n < len(data); n++ {
+		files = append(files, string(data[:n]))
+	}
+
+	for _, file := range files {
+		path := filepath.Join(dir, "corrupted")
+		if err := os.WriteFile(path, []byte(file), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadReplay(path); err == nil {
+			t.Errorf("no error for %q", file)
+		}
+	}
+}
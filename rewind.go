@@ -0,0 +1,76 @@
+package main
+
+type Rewind struct {
+	Paused bool
+
+	frames []rewindFrame
+	head   int // index of the newest frame
+	count  int
+	pos    int // how many frames back from the newest one we are
+}
+
+type rewindFrame struct {
+	step int
+	snap *Snapshot
+}
+
+func NewRewind(seconds float32) *Rewind {
+	return &Rewind{
+		frames: make([]rewindFrame, int(seconds*StepRate)),
+	}
+}
+
+func (r *Rewind) Push(step int, snap *Snapshot) {
+	if r.pos > 0 {
+		// the timeline was changed, frames after the current one are gone
+		r.head = r.index(r.pos)
+		r.count -= r.pos
+		r.pos = 0
+	}
+
+	r.head = (r.head + 1) % len(r.frames)
+	r.frames[r.head] = rewindFrame{step: step, snap: snap}
+	if r.count < len(r.frames) {
+		r.count += 1
+	}
+}
+
+func (r *Rewind) Back(game *Game) bool {
+	if r.pos+1 >= r.count {
+		return false
+	}
+	r.pos += 1
+	PanicOnError(game.Restore(r.frames[r.index(r.pos)].snap))
+	return true
+}
+
+func (r *Rewind) Forward(game *Game) bool {
+	if r.pos == 0 {
+		return false
+	}
+	r.pos -= 1
+	PanicOnError(game.Restore(r.frames[r.index(r.pos)].snap))
+	return true
+}
+
+func (r *Rewind) InPast() bool {
+	return r.pos > 0
+}
+
+func (r *Rewind) Step() int {
+	return r.frames[r.index(r.pos)].step
+}
+
+func (r *Rewind) Reset() {
+	for i := range r.frames {
+		r.frames[i] = rewindFrame{}
+	}
+	r.head = 0
+	r.count = 0
+	r.pos = 0
+	r.Paused = false
+}
+
+func (r *Rewind) index(back int) int {
+	return (r.head - back + len(r.frames)) % len(r.frames)
+}
@@ -2,26 +2,41 @@ package main
 
 import (
 	"math"
-	"math/rand"
 
 	mgl "github.com/go-gl/mathgl/mgl32"
 )
 
 type Ship struct {
-	Race   Race
-	IsDead bool
-	Pos    mgl.Vec2
-	Dir    mgl.Vec2
+	Race         Race
+	IsDead       bool
+	Invulnerable bool
+	Pos          mgl.Vec2
+	Dir          mgl.Vec2
+	Pilot        Pilot
 
 	velocity mgl.Vec2
+	impact   float32 // fraction of the step move before a ram, 0 is none
 	model    *ShipModel
 	hp       int
 	damaged  bool
 	trs      mgl.Mat3
 
+	hull   []mgl.Vec2 // world space hull, updated with trs
+	inner  []mgl.Vec2 // half-sized hull for Color2 layer
+	layers [][]mgl.Vec2
+	solid  []mgl.Vec2 // world space collision triangles
+	sides  [][2]mgl.Vec2
+
 	fire     bool
 	cooldown []float32
+	spin     []float32 // spiral emitter angles
+	turret   []float32 // gun angles from the mount
 	engineCD []float32
+
+	parts     []shipPart
+	gunOff    []bool // guns and engines of wrecked parts
+	engineOff []bool
+	gunsOn    []bool // selected guns, nil is all
 }
 
 func NewShip(race Race, model *ShipModel) *Ship {
@@ -31,12 +46,16 @@ func NewShip(race Race, model *ShipModel) *Ship {
 		Dir:      mgl.Vec2{1, 0},
 		hp:       model.Hp,
 		cooldown: make([]float32, len(model.Guns)),
+		spin:     make([]float32, len(model.Guns)),
+		turret:   make([]float32, len(model.Guns)),
 		engineCD: make([]float32, len(model.Engines)),
 	}
 
 	if race != Human {
 		s.Dir[0] *= -1
 	}
+	s.syncParts()
+	s.updatePose()
 
 	return s
 }
@@ -51,13 +70,40 @@ func (s *Ship) Control(thrust mgl.Vec2, fire bool) {
 	}
 }
 
-func (s *Ship) Update(dt float32, world *World, ships []*Ship) {
-	for _, other := range ships {
-		if s.Race != other.Race && s.collides(other) {
-			other.Hit()
-			s.Hit()
+// Collide checks the ship way for the step against the ways of other
+// ships, before any of them moves. Both ships of a ram take a hit, each in
+// its own Collide.
+func (s *Ship) Collide(dt float32, world *World) {
+	move := s.velocity.Mul(dt)
+
+	aabb := s.AABB()
+	aabb = mgl.Vec4{
+		aabb[0] + Min(move.X(), 0),
+		aabb[1] + Min(move.Y(), 0),
+		aabb[2] + Max(move.X(), 0),
+		aabb[3] + Max(move.Y(), 0),
+	}
+	for _, other := range world.ShipsNear(aabb) {
+		if s.Race == other.Race {
+			continue
+		}
+		otherMove := other.velocity.Mul(dt)
+		if ok, t, _ := s.collides(other, move.Sub(otherMove)); ok {
+			s.Hit(1)
+			if t > 0 && (s.impact == 0 || t < s.impact) {
+				s.impact = t // rammed, stop at the impact point
+			}
 		}
 	}
+}
+
+func (s *Ship) Update(dt float32, world *World) {
+	move := s.velocity.Mul(dt)
+	toi := float32(1)
+	if s.impact > 0 {
+		toi = s.impact
+		s.impact = 0
+	}
 
 	if s.hp <= 0 {
 		s.IsDead = true
@@ -65,23 +111,35 @@ func (s *Ship) Update(dt float32, world *World, ships []*Ship) {
 		return
 	}
 
-	s.Pos = s.Pos.Add(s.velocity.Mul(dt))
+	s.Pos = s.Pos.Add(move.Mul(toi))
 	s.StayInWorld(world)
-	s.trs = s.calcTRS(1)
+	s.updatePose()
+	s.updateParts(world)
 	s.updateGuns(dt, world)
 	s.updateEngines(dt, world)
 }
 
 func (s *Ship) updateGuns(dt float32, world *World) {
 	for i, gun := range s.model.Guns {
+		if s.gunOff[i] || (s.gunsOn != nil && !s.gunsOn[i]) {
+			continue
+		}
+		s.turnTurret(dt, world, i)
+		dir := s.gunDir(i)
+
 		s.cooldown[i] -= dt
 		for ; s.fire && s.cooldown[i] < 0; s.cooldown[i] += 1 / gun.Rate {
 			pos := s.transformPoint(gun.Pos)
-			v := s.Dir.Mul(gun.Speed)
-			m := NewMissile(s.Race, pos, v, gun.Size, gun.Color)
-			world.AddMissiles(m)
+			if gun.Emitter != nil {
+				gun.Emitter.Emit(world, s, &gun, pos, dir, &s.spin[i])
+			} else {
+				v := dir.Mul(gun.Speed)
+				m := world.NewMissile(s.Race, pos, v, gun.Size, gun.Color)
+				m.arm(&gun)
+				world.AddMissiles(m)
+			}
 			if len(gun.Sound) > 0 {
-				PlaySound(gun.Sound, gun.SoundGain, gun.SoundPitch)
+				world.PlaySound(gun.Sound, gun.SoundGain, gun.SoundPitch)
 			}
 		}
 		if !s.fire && s.cooldown[i] < 0 {
@@ -90,11 +148,56 @@ func (s *Ship) updateGuns(dt float32, world *World) {
 	}
 }
 
+// turnTurret turns a tracking gun to the nearest enemy within its arc.
+func (s *Ship) turnTurret(dt float32, world *World, i int) {
+	gun := &s.model.Guns[i]
+	if gun.TurnRate <= 0 {
+		return
+	}
+
+	want := s.turret[i]
+	pos := s.transformPoint(gun.Pos)
+	mount := mgl.Rotate2D(gun.Angle).Mul2x1(s.Dir)
+	arc := gun.Arc
+	if arc <= 0 {
+		arc = math.Pi
+	}
+	races := enemyRaces(s.Race)
+	target := world.NearestShipInCone(pos, mount, arc, races...)
+	if target != nil {
+		if dir := direction(pos, target.Pos); dir != (mgl.Vec2{}) {
+			want = angleBetween(mount, dir)
+		}
+	}
+	if gun.Arc > 0 {
+		want = mgl.Clamp(want, -gun.Arc, gun.Arc)
+	}
+
+	turn := gun.TurnRate * dt
+	diff := want - s.turret[i]
+	if gun.Arc <= 0 {
+		diff = wrapAngle(diff) // turn the short way, there are no limits
+	}
+	s.turret[i] = wrapAngle(s.turret[i] + mgl.Clamp(diff, -turn, turn))
+}
+
+// gunDir is where a gun fires, along its mount turned by the turret.
+func (s *Ship) gunDir(i int) mgl.Vec2 {
+	angle := s.model.Guns[i].Angle + s.turret[i]
+	if angle == 0 {
+		return s.Dir
+	}
+	return mgl.Rotate2D(angle).Mul2x1(s.Dir).Normalize()
+}
+
 func (s *Ship) updateEngines(dt float32, world *World) {
 	const speed = 100
 	const MaxSideVelocity = 90
 
 	for i, engine := range s.model.Engines {
+		if s.engineOff[i] {
+			continue
+		}
 		s.engineCD[i] -= dt
 
 		proj := s.velocity.Dot(s.Dir)
@@ -102,16 +205,16 @@ func (s *Ship) updateEngines(dt float32, world *World) {
 		active := engine.MinVelocity <= proj && MaxSideVelocity > rej.Len()
 
 		for ; active && s.engineCD[i] < 0; s.engineCD[i] += 1 / engine.Rate {
-			shift := rand.Float32() - 0.5
+			shift := world.FxRand.Float32() - 0.5
 			pos := s.transformPoint(engine.Pos)
 			posDir := mgl.Vec2{-s.Dir.Y(), s.Dir.X()}
 			pos = pos.Add(posDir.Mul(shift * engine.Size / 2))
 
-			particle := NewParticle(
+			particle := world.NewParticle(
 				pos,
 				engine.ParticleSize[1],
 				engine.ParticleSize[0],
-				float32(engine.TTL)+engine.TTL*rand.Float32(),
+				float32(engine.TTL)+engine.TTL*world.FxRand.Float32(),
 				engine.Color,
 			)
 			particle.Velocity = s.Dir.Mul(-speed)
@@ -124,19 +227,29 @@ func (s *Ship) updateEngines(dt float32, world *World) {
 	}
 }
 
-func (s *Ship) Draw(renderer *Renderer) {
+func (s *Ship) Draw(renderer Renderer) {
 	if s.damaged {
 		s.damaged = false
-		renderer.Draw(s.transform(s.model.Hull), WhiteColor, PlainGroup)
+		renderer.Draw(s.hull, WhiteColor, PlainGroup)
 	} else {
-		renderer.Draw(s.transform(s.model.Hull), s.model.Color1, PlainGroup)
-		renderer.Draw(s.transformScale(s.model.Hull, 0.5), s.model.Color2, PlainGroup)
+		renderer.Draw(s.hull, s.model.Color1, PlainGroup)
+		if len(s.model.Layers) == 0 {
+			renderer.Draw(s.inner, s.model.Color2, PlainGroup)
+		}
+		for i, layer := range s.model.Layers {
+			color := layer.Color
+			if color == (mgl.Vec4{}) {
+				color = s.model.Color2
+			}
+			renderer.Draw(s.layers[i], color, PlainGroup)
+		}
 	}
+	s.drawParts(renderer)
 }
 
-func (s *Ship) Hit() {
-	if s.Race != Autopilot {
-		s.hp -= 1
+func (s *Ship) Hit(damage int) {
+	if s.Race != Autopilot && !s.Invulnerable {
+		s.hp -= damage
 		s.damaged = true
 	}
 }
@@ -153,21 +266,7 @@ func (s *Ship) AABB() mgl.Vec4 {
 }
 
 func (s *Ship) Sides() [][2]mgl.Vec2 {
-	points := s.transform(s.model.Hull)
-
-	sides := make([][2]mgl.Vec2, 0, len(points))
-	for i := 0; i < len(points)/3; i++ {
-		p1 := points[i*3+0]
-		p2 := points[i*3+1]
-		p3 := points[i*3+2]
-		sides = append(sides,
-			[2]mgl.Vec2{p1, p2},
-			[2]mgl.Vec2{p2, p3},
-			[2]mgl.Vec2{p3, p1},
-		)
-	}
-
-	return sides
+	return s.sides
 }
 
 func (s *Ship) StayInWorld(world *World) {
@@ -184,10 +283,27 @@ func (s *Ship) StayInWorld(world *World) {
 	}
 }
 
+// SelectGuns leaves only the given guns to fire, none means all of them.
+func (s *Ship) SelectGuns(guns []int) {
+	s.gunsOn = nil
+	if len(guns) > 0 {
+		s.gunsOn = make([]bool, len(s.model.Guns))
+		for _, gun := range guns {
+			s.gunsOn[gun] = true
+		}
+	}
+}
+
 func (s *Ship) Health() float32 {
 	return float32(s.hp) / float32(s.model.Hp)
 }
 
+// Kill destroys the ship on its next update, even an invulnerable one.
+func (s *Ship) Kill() {
+	s.hp = 0
+	s.damaged = true
+}
+
 func (s *Ship) Revive() {
 	s.hp = s.model.Hp
 	s.IsDead = false
@@ -204,39 +320,103 @@ func (s *Ship) calcTRS(scale float32) mgl.Mat3 {
 	return mat
 }
 
-func (s *Ship) transform(points []mgl.Vec2) []mgl.Vec2 {
-	result := make([]mgl.Vec2, len(points))
-	for i, p := range points {
-		result[i] = s.trs.Mul3x1(mgl.Vec3{p.X(), p.Y(), 1}).Vec2()
-	}
-	return result
-}
-
 func (s *Ship) transformPoint(p mgl.Vec2) mgl.Vec2 {
 	return s.trs.Mul3x1(mgl.Vec3{p.X(), p.Y(), 1}).Vec2()
 }
 
-func (s *Ship) transformScale(points []mgl.Vec2, scale float32) []mgl.Vec2 {
-	mat := s.calcTRS(scale)
+// updatePose moves the hull to the ship position and direction.
+func (s *Ship) updatePose() {
+	s.trs = s.calcTRS(1)
+	s.updateHull()
+}
+
+func (s *Ship) updateHull() {
+	model := s.model
+	if len(s.hull) != len(model.Hull) {
+		s.hull = make([]mgl.Vec2, len(model.Hull))
+		s.inner = make([]mgl.Vec2, len(model.Hull))
+	}
+	if len(s.solid) != len(model.Solid) {
+		s.solid = make([]mgl.Vec2, len(model.Solid))
+	}
+	if len(s.sides) != len(model.Outline) {
+		s.sides = make([][2]mgl.Vec2, len(model.Outline))
+	}
+
+	for i, p := range model.Hull {
+		s.hull[i] = s.transformPoint(p)
+		s.inner[i] = s.transformPoint(p.Mul(0.5))
+	}
+	for i, p := range model.Solid {
+		s.solid[i] = s.transformPoint(p)
+	}
+	s.updatePartHulls()
+
+	if len(s.layers) != len(model.Layers) {
+		s.layers = make([][]mgl.Vec2, len(model.Layers))
+	}
+	for i, layer := range model.Layers {
+		if len(s.layers[i]) != len(layer.Hull) {
+			s.layers[i] = make([]mgl.Vec2, len(layer.Hull))
+		}
+		for j, p := range layer.Hull {
+			s.layers[i][j] = s.transformPoint(p)
+		}
+	}
+
+	for i, p := range model.Outline {
+		next := model.Outline[(i+1)%len(model.Outline)]
+		s.sides[i] = [2]mgl.Vec2{s.transformPoint(p), s.transformPoint(next)}
+	}
+}
+
+// syncModel adapts ship state to a model that was changed in place.
+func (s *Ship) syncModel() {
+	s.cooldown = resizeCooldowns(s.cooldown, len(s.model.Guns))
+	s.spin = resizeCooldowns(s.spin, len(s.model.Guns))
+	s.turret = resizeCooldowns(s.turret, len(s.model.Guns))
+	if len(s.gunsOn) != len(s.model.Guns) {
+		s.gunsOn = nil
+	}
+	s.engineCD = resizeCooldowns(s.engineCD, len(s.model.Engines))
+	if s.hp > s.model.Hp {
+		s.hp = s.model.Hp
+	}
+	s.syncParts()
+	s.updateHull()
+}
 
-	result := make([]mgl.Vec2, len(points))
-	for i, p := range points {
-		result[i] = mat.Mul3x1(mgl.Vec3{p.X(), p.Y(), 1}).Vec2()
+func resizeCooldowns(cooldowns []float32, count int) []float32 {
+	if len(cooldowns) == count {
+		return cooldowns
 	}
-	return result
+	resized := make([]float32, count)
+	copy(resized, cooldowns)
+	return resized
 }
 
 func (s *Ship) makeExplosion(world *World) {
-	boomTTL := 0.5 * s.model.BlowupFactor
-	size := 15 * s.model.BlowupFactor
-	ttl := 2 * s.model.BlowupFactor
-	count := 35 * s.model.BlowupFactor
-	velocityMin := 50 * s.model.BlowupFactor
-	velocityMax := 200 * s.model.BlowupFactor
-
-	bigBoom := NewParticle(
-		s.Pos,
-		Max(s.model.Size.Elem())*s.model.BlowupFactor,
+	size := Max(s.model.Size.Elem())
+	s.explode(world, s.Pos, size, s.model.BlowupFactor)
+
+	if s.Race == Human {
+		world.PlaySound("boom", 1, 0.3)
+	} else {
+		world.PlaySound("boom", 1, 1/s.model.BlowupFactor)
+	}
+}
+
+func (s *Ship) explode(world *World, pos mgl.Vec2, size, factor float32) {
+	boomTTL := 0.5 * factor
+	particleSize := 15 * factor
+	ttl := 2 * factor
+	count := 35 * factor
+	velocityMin := 50 * factor
+	velocityMax := 200 * factor
+
+	bigBoom := world.NewParticle(
+		pos,
+		size*factor,
 		0,
 		boomTTL,
 		WhiteColor,
@@ -247,39 +427,24 @@ func (s *Ship) makeExplosion(world *World) {
 	for i := 0; i < int(count); i++ {
 		angleMin := float64(i) * 2 * math.Pi / float64(count)
 		angleMax := float64(i+1) * 2 * math.Pi / float64(count)
-		angle := angleMin + (angleMax-angleMin)*rand.Float64()
+		angle := angleMin + (angleMax-angleMin)*world.FxRand.Float64()
 		sin, cos := math.Sincos(angle)
 
-		v := velocityMin + (velocityMax-velocityMin)*rand.Float32()
-		p := NewParticle(
-			s.Pos,
-			(size/2)*rand.Float32()+size/2,
+		v := velocityMin + (velocityMax-velocityMin)*world.FxRand.Float32()
+		p := world.NewParticle(
+			pos,
+			(particleSize/2)*world.FxRand.Float32()+particleSize/2,
 			0,
-			(ttl/2)*rand.Float32()+ttl/2,
-			colors[rand.Intn(2)],
+			(ttl/2)*world.FxRand.Float32()+ttl/2,
+			colors[world.FxRand.Intn(2)],
 		)
 		p.Velocity = mgl.Vec2{float32(sin) * v, float32(cos) * v}
 		world.AddObjects(p)
 	}
-
-	if s.Race == Human {
-		PlaySound("boom", 1, 0.3)
-	} else {
-		PlaySound("boom", 1, 1/s.model.BlowupFactor)
-	}
 }
 
-func (s *Ship) collides(other *Ship) bool {
-	if CheckAABB(s.AABB(), other.AABB()) {
-		for _, side := range s.Sides() {
-			for _, oSide := range other.Sides() {
-				ok, _ := SegmentIntersection(side[0], side[1],
-					oSide[0], oSide[1])
-				if ok {
-					return true
-				}
-			}
-		}
-	}
-	return false
+// collides sweeps the ship by move relative to the other ship, both are
+// where they were at the start of the step.
+func (s *Ship) collides(other *Ship, move mgl.Vec2) (bool, float32, Contact) {
+	return HullsSweep(s.solid, other.solid, move)
 }
@@ -0,0 +1,377 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+)
+
+// Sketch is a flat drawing in pixels with y pointing down, it can be saved
+// as SVG or rasterized to PNG without GL.
+type Sketch struct {
+	Size   mgl.Vec2
+	fills  []sketchFill
+	lines  []sketchLine
+	labels []sketchLabel
+}
+
+type sketchFill struct {
+	rings [][]mgl.Vec2 // outline and holes
+	color mgl.Vec4
+}
+
+type sketchLine struct {
+	a, b  mgl.Vec2
+	width float32
+	color mgl.Vec4
+}
+
+type sketchLabel struct {
+	pos   mgl.Vec2
+	text  string
+	color mgl.Vec4
+}
+
+var (
+	viewBackground = HexColor("#111111", 1)
+	viewAABBColor  = HexColor("#808080", 1)
+	viewMarkColor  = HexColor("#ffffff", 1)
+)
+
+// RunShipView is the shipview command:
+//
+//	shmup shipview [-models file] [-o file] [-format svg|png] model...
+func RunShipView(args []string) error {
+	flags := flag.NewFlagSet("shipview", flag.ExitOnError)
+	models := flags.String("models", "", "load ship models from file")
+	output := flags.String("o", "", "output file, default is model name")
+	format := flags.String("format", "svg", "svg or png, if -o is not set")
+	scale := flags.Float64("scale", 4, "pixels per world unit")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(),
+			"usage: shmup shipview [flags] model...\nflags:")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if *models != "" {
+		loaded, err := LoadModels(*models)
+		if err != nil {
+			return err
+		}
+		ApplyModels(loaded)
+	}
+
+	names := flags.Args()
+	if len(names) == 0 {
+		flags.Usage()
+		return errors.New("no models given")
+	}
+	if *output != "" && len(names) > 1 {
+		return errors.New("-o works with a single model only")
+	}
+
+	for _, name := range names {
+		model, found := ShipModels[name]
+		if !found {
+			return fmt.Errorf("unknown ship model %q", name)
+		}
+
+		path := *output
+		if path == "" {
+			path = name + "." + *format
+		}
+		sketch := NewModelSketch(model, float32(*scale))
+		if err := sketch.Save(path); err != nil {
+			return err
+		}
+		fmt.Println(name, "saved to", path)
+	}
+	return nil
+}
+
+// NewModelSketch draws a model the way the game does with the nose up,
+// and marks parts, guns with their arcs, engines and the AABB on top.
+func NewModelSketch(model *ShipModel, scale float32) *Sketch {
+	halfSize := Max(model.Size.X(), model.Size.Y()) / 2
+	margin := halfSize / 2
+	side := (halfSize + margin) * 2 * scale
+	center := mgl.Vec2{side / 2, side / 2}
+
+	toView := func(p mgl.Vec2) mgl.Vec2 {
+		x := p.X() * model.Size.X() / 2 * scale
+		y := p.Y() * model.Size.Y() / 2 * scale
+		return center.Add(mgl.Vec2{x, -y})
+	}
+	rings := func(outline []mgl.Vec2, holes [][]mgl.Vec2,
+		k float32) [][]mgl.Vec2 {
+
+		var result [][]mgl.Vec2
+		for _, ring := range append([][]mgl.Vec2{outline}, holes...) {
+			points := make([]mgl.Vec2, len(ring))
+			for i, p := range ring {
+				points[i] = toView(p.Mul(k))
+			}
+			result = append(result, points)
+		}
+		return result
+	}
+
+	sketch := &Sketch{Size: mgl.Vec2{side, side}}
+	sketch.fill(rings(model.Outline, model.Holes, 1), model.Color1)
+	if len(model.Layers) == 0 {
+		sketch.fill(rings(model.Outline, model.Holes, 0.5), model.Color2)
+	}
+	for _, layer := range model.Layers {
+		color := layer.Color
+		if color == (mgl.Vec4{}) {
+			color = model.Color2
+		}
+		sketch.fill(rings(layer.Outline, layer.Holes, 1), color)
+	}
+	for _, part := range model.Parts {
+		color := part.Color
+		if color == (mgl.Vec4{}) {
+			color = model.Color2
+		}
+		outline := rings(part.Outline, nil, 1)
+		sketch.fill(outline, color)
+		for i, p := range outline[0] {
+			next := outline[0][(i+1)%len(outline[0])]
+			sketch.line(p, next, 1, viewMarkColor)
+		}
+		sketch.label(outline[0][0], part.Name, viewMarkColor)
+	}
+
+	half := halfSize * scale
+	corners := []mgl.Vec2{
+		center.Add(mgl.Vec2{-half, -half}),
+		center.Add(mgl.Vec2{half, -half}),
+		center.Add(mgl.Vec2{half, half}),
+		center.Add(mgl.Vec2{-half, half}),
+	}
+	for i := range corners {
+		sketch.line(corners[i], corners[(i+1)%4], 1, viewAABBColor)
+	}
+	sketch.label(corners[0].Add(mgl.Vec2{0, -4}), "AABB", viewAABBColor)
+
+	arrow := margin * scale * 0.8
+	viewDir := func(angle float32) mgl.Vec2 {
+		dir := mgl.Rotate2D(angle).Mul2x1(mgl.Vec2{0, 1})
+		return mgl.Vec2{dir.X(), -dir.Y()}
+	}
+	for i, gun := range model.Guns {
+		color := opaque(gun.Color)
+		pos := toView(gun.Pos)
+		dir := viewDir(gun.Angle)
+		side := mgl.Vec2{-dir.Y(), dir.X()}
+		tip := pos.Add(dir.Mul(arrow))
+		back := tip.Sub(dir.Mul(6))
+		sketch.line(pos, tip, 2, color)
+		sketch.line(tip, back.Sub(side.Mul(4)), 2, color)
+		sketch.line(tip, back.Add(side.Mul(4)), 2, color)
+		if gun.TurnRate > 0 && gun.Arc > 0 {
+			for _, limit := range []float32{-gun.Arc, gun.Arc} {
+				end := pos.Add(viewDir(gun.Angle + limit).Mul(arrow / 2))
+				sketch.line(pos, end, 1, color)
+			}
+		}
+		sketch.circle(pos, 3, viewMarkColor)
+		sketch.label(tip.Add(mgl.Vec2{6, 0}), fmt.Sprintf("gun %d", i+1),
+			color)
+	}
+
+	for i, engine := range model.Engines {
+		color := opaque(engine.Color)
+		pos := toView(engine.Pos)
+		width := engine.Size / 2 * scale // particles spread this wide
+		sketch.line(pos.Add(mgl.Vec2{-width / 2, 0}),
+			pos.Add(mgl.Vec2{width / 2, 0}), 2, color)
+		sketch.line(pos, pos.Add(mgl.Vec2{0, arrow / 2}), 1, color)
+		sketch.circle(pos, 3, viewMarkColor)
+		sketch.label(pos.Add(mgl.Vec2{6, arrow / 2}),
+			fmt.Sprintf("engine %d", i+1), color)
+	}
+
+	return sketch
+}
+
+func (s *Sketch) fill(rings [][]mgl.Vec2, color mgl.Vec4) {
+	s.fills = append(s.fills, sketchFill{rings, color})
+}
+
+func (s *Sketch) line(a, b mgl.Vec2, width float32, color mgl.Vec4) {
+	s.lines = append(s.lines, sketchLine{a, b, width, color})
+}
+
+func (s *Sketch) circle(pos mgl.Vec2, radius float32, color mgl.Vec4) {
+	const sides = 12
+	ring := make([]mgl.Vec2, sides)
+	for i := range ring {
+		a := float64(i) * 2 * math.Pi / sides
+		ring[i] = pos.Add(mgl.Vec2{
+			radius * float32(math.Cos(a)),
+			radius * float32(math.Sin(a)),
+		})
+	}
+	s.fill([][]mgl.Vec2{ring}, color)
+}
+
+func (s *Sketch) label(pos mgl.Vec2, text string, color mgl.Vec4) {
+	s.labels = append(s.labels, sketchLabel{pos, text, color})
+}
+
+// Save picks the format by file extension.
+func (s *Sketch) Save(path string) error {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".svg":
+		return os.WriteFile(path, []byte(s.SVG()), 0644)
+	case ".png":
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return png.Encode(f, s.Rasterize())
+	}
+	return fmt.Errorf("%s: unknown image format", path)
+}
+
+func (s *Sketch) SVG() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" `+
+		`width="%g" height="%g">`+"\n", s.Size.X(), s.Size.Y())
+	fmt.Fprintf(&b, `<rect width="100%%" height="100%%" %s/>`+"\n",
+		svgPaint("fill", viewBackground))
+
+	for _, f := range s.fills {
+		b.WriteString(`<path d="`)
+		for _, ring := range f.rings {
+			for i, p := range ring {
+				cmd := "L"
+				if i == 0 {
+					cmd = "M"
+				}
+				fmt.Fprintf(&b, "%s%.2f,%.2f ", cmd, p.X(), p.Y())
+			}
+			b.WriteString("Z ")
+		}
+		fmt.Fprintf(&b, `" fill-rule="evenodd" %s/>`+"\n",
+			svgPaint("fill", f.color))
+	}
+	for _, l := range s.lines {
+		fmt.Fprintf(&b, `<line x1="%.2f" y1="%.2f" x2="%.2f" y2="%.2f" `+
+			`stroke-width="%g" %s/>`+"\n", l.a.X(), l.a.Y(), l.b.X(),
+			l.b.Y(), l.width, svgPaint("stroke", l.color))
+	}
+	for _, l := range s.labels {
+		fmt.Fprintf(&b, `<text x="%.2f" y="%.2f" font-family="monospace" `+
+			`font-size="10" %s>%s</text>`+"\n", l.pos.X(), l.pos.Y(),
+			svgPaint("fill", l.color), html.EscapeString(l.text))
+	}
+
+	b.WriteString("</svg>\n")
+	return b.String()
+}
+
+func svgPaint(attr string, color mgl.Vec4) string {
+	paint := fmt.Sprintf(`%s="%s"`, attr, FormatColor(opaque(color)))
+	if color[3] < 1 {
+		paint += fmt.Sprintf(` %s-opacity="%.3g"`, attr, color[3])
+	}
+	return paint
+}
+
+// Rasterize draws the sketch without labels, there is no font to use.
+func (s *Sketch) Rasterize() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, int(s.Size.X()), int(s.Size.Y())))
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			img.Set(x, y, rgba(viewBackground))
+		}
+	}
+
+	for _, f := range s.fills {
+		triangles, err := Triangulate(f.rings[0], f.rings[1:])
+		if err != nil {
+			continue // nothing sensible to draw
+		}
+		for i := 0; i+2 < len(triangles); i += 3 {
+			fillTriangle(img, triangles[i:i+3], f.color)
+		}
+	}
+
+	for _, l := range s.lines {
+		dir := l.b.Sub(l.a)
+		if dir.Len() < triangulateEps {
+			continue
+		}
+		side := mgl.Vec2{-dir.Y(), dir.X()}.Normalize().Mul(l.width / 2)
+		a1, a2 := l.a.Sub(side), l.a.Add(side)
+		b1, b2 := l.b.Sub(side), l.b.Add(side)
+		fillTriangle(img, []mgl.Vec2{a1, b1, b2}, l.color)
+		fillTriangle(img, []mgl.Vec2{a1, b2, a2}, l.color)
+	}
+
+	return img
+}
+
+// fillTriangle blends color into pixels whose centers are inside of the
+// triangle, winding doesn't matter.
+func fillTriangle(img *image.RGBA, tri []mgl.Vec2, c mgl.Vec4) {
+	a, b, p := tri[0], tri[1], tri[2]
+	if polygonArea(tri) < 0 {
+		b, p = p, b
+	}
+
+	min := MinVec2(a, b, p)
+	max := MaxVec2(a, b, p)
+	rect := image.Rect(int(min.X()), int(min.Y()),
+		int(max.X())+1, int(max.Y())+1).Intersect(img.Bounds())
+
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			center := mgl.Vec2{float32(x) + 0.5, float32(y) + 0.5}
+			if pointInTriangle(center, a, b, p) {
+				blendPixel(img, x, y, c)
+			}
+		}
+	}
+}
+
+func blendPixel(img *image.RGBA, x, y int, c mgl.Vec4) {
+	dst := img.RGBAAt(x, y)
+	blend := func(d uint8, s float32) uint8 {
+		return uint8(float32(d)*(1-c[3]) + s*255*c[3] + 0.5)
+	}
+	img.SetRGBA(x, y, color.RGBA{
+		blend(dst.R, c[0]),
+		blend(dst.G, c[1]),
+		blend(dst.B, c[2]),
+		255,
+	})
+}
+
+func rgba(c mgl.Vec4) color.RGBA {
+	return color.RGBA{
+		uint8(c[0]*255 + 0.5),
+		uint8(c[1]*255 + 0.5),
+		uint8(c[2]*255 + 0.5),
+		uint8(c[3]*255 + 0.5),
+	}
+}
+
+func opaque(c mgl.Vec4) mgl.Vec4 {
+	return mgl.Vec4{c[0], c[1], c[2], 1}
+}
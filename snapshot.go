@@ -0,0 +1,977 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+)
+
+const (
+	snapshotMagic   = "SHSN"
+	snapshotVersion = 5
+)
+
+// Snapshot is a complete copy of the game and world state. Objects that
+// refer to each other (stages, ships and formations) are linked by indices
+// into Ships, Objects and Formations.
+type Snapshot struct {
+	Seed      int64
+	Rand      uint64
+	FxRand    uint64
+	TimeSpeed float32
+
+	Ships      []ShipState
+	WorldShips int // first WorldShips ships are in the world
+	Missiles   []MissileState
+	Objects    []ObjectState
+	Formations []FormationState
+
+	Player     int
+	Stars      int
+	Stages     []StageState
+	Stage      *StageState
+	StageIndex int
+	NextStage  int
+	Finished   bool
+}
+
+type ShipState struct {
+	Race         Race
+	IsDead       bool
+	Invulnerable bool
+	Pos          mgl.Vec2
+	Dir          mgl.Vec2
+	Velocity     mgl.Vec2
+	Model        string
+	Hp           int
+	Damaged      bool
+	TRS          mgl.Mat3
+	Fire         bool
+	Cooldown     []float32
+	Spin         []float32
+	Turret       []float32
+	EngineCD     []float32
+	Pilot        *PilotState
+	Parts        []PartState
+	GunsOn       []bool
+}
+
+type PartState struct {
+	Hp      int
+	Damaged bool
+	Wrecked bool
+}
+
+type MissileState struct {
+	IsDead   bool
+	Race     Race
+	Pos      mgl.Vec2
+	Velocity mgl.Vec2
+	Size     mgl.Vec2
+	Color    mgl.Vec4
+	Dir      mgl.Vec2
+	Accel    float32
+	EndSpeed float32
+
+	Damage       int
+	Pierce       int
+	Blast        float32
+	FriendlyFire bool
+	Pierced      []int
+
+	Homing  *Homing
+	Target  int
+	Fuel    float32
+	SmokeCD float32
+}
+
+type ObjectState struct {
+	Particle  *ParticleState
+	Starfield []StratumState
+}
+
+type ParticleState struct {
+	Velocity    mgl.Vec2
+	RenderGroup PolyGroup
+	Dead        bool
+	Pos         mgl.Vec2
+	StartSize   float32
+	EndSize     float32
+	Color       mgl.Vec4
+	Lifetime    float32
+	TTL         float32
+}
+
+type StratumState struct {
+	Size        mgl.Vec2
+	Stars       []Star
+	StripeCount int
+	Speed       float32
+	SpeedFactor float32
+	Color       mgl.Vec4
+	MinStarSize float32
+	MaxStarSize float32
+	LastX       float32
+	LastStripe  int
+}
+
+type StageState struct {
+	Cargo   *CargoStage
+	Fighter bool
+	Intro   *IntroStageState
+	Outro   *OutroStageState
+	Final   *FinalStageState
+	Death   *DeathStageState
+	Wave    *WaveStageState
+}
+
+type IntroStageState struct {
+	Stars     int
+	Ship      int
+	Time      float32
+	TotalTime float32
+	Sound     bool
+}
+
+type OutroStageState struct {
+	Stars     int
+	Ship      int
+	Time      float32
+	TotalTime float32
+	Started   bool
+}
+
+type FinalStageState struct {
+	Boss       string
+	Phases     []PhaseDef
+	BossShip   int
+	BossSound  bool
+	Phase      int
+	Time       float32
+	Transition float32
+	Escorts    [][]int
+}
+
+type DeathStageState struct {
+	Ship    int
+	Time    float32
+	Started bool
+}
+
+type WaveStageState struct {
+	Spawns    []SpawnDef
+	Until     string
+	Time      float32
+	T         float32
+	NextSpawn int
+	Ships     []int
+}
+
+type PilotState struct {
+	Advancer *Advancer
+	Round    *RoundShooterState
+	Steering *SteeringPilotState
+	Path     *PathFollowerState
+	Formed   *FormationPilotState
+}
+
+type RoundShooterState struct {
+	EndPosX  float32
+	AngleMin float32
+	AngleMax float32
+	Dir      mgl.Vec2
+	T        float32
+}
+
+type SteeringPilotState struct {
+	Move *SteeringState
+	Aim  *AimAtPlayer
+	Fire bool
+}
+
+// PathFollowerState keeps the path definition, the path is sampled again
+// on load.
+type PathFollowerState struct {
+	Path    PathDef
+	Speed   float32
+	Mirror  bool
+	Start   mgl.Vec2
+	Dist    float32
+	Started bool
+}
+
+type FormationPilotState struct {
+	Formation int
+	Attack    *PilotState
+}
+
+type FormationState struct {
+	Formation Formation // settings only
+	Members   []int
+	Time      float32
+	Broken    bool
+}
+
+type SteeringState struct {
+	Seek   *Seek
+	Flee   *Flee
+	Arrive *Arrive
+	Strafe *StrafeState
+	Orbit  *Orbit
+	Blend  []WeightedState
+}
+
+type StrafeState struct {
+	Target Target
+	Period float32
+	T      float32
+}
+
+type WeightedState struct {
+	Steering SteeringState
+	Weight   float32
+}
+
+type snapshotWriter struct {
+	snap       *Snapshot
+	ships      map[*Ship]int
+	objects    map[*StarStratum]int
+	formations map[*Formation]int
+	formed     []*Formation // in the snapshot order
+}
+
+type snapshotReader struct {
+	snap       *Snapshot
+	world      *World
+	ships      []*Ship
+	objects    []WorldObject
+	formations []*Formation
+}
+
+func (game *Game) Snapshot() *Snapshot {
+	world := game.world
+	snap := &Snapshot{
+		Seed:       world.Seed,
+		Rand:       world.randSrc.State,
+		FxRand:     world.fxRandSrc.State,
+		TimeSpeed:  world.TimeSpeed,
+		WorldShips: len(world.ships),
+		StageIndex: -1,
+		NextStage:  game.nextStage,
+		Finished:   game.finished,
+	}
+	sw := &snapshotWriter{
+		snap:       snap,
+		ships:      make(map[*Ship]int),
+		objects:    make(map[*StarStratum]int),
+		formations: make(map[*Formation]int),
+	}
+
+	for _, s := range world.ships {
+		sw.ship(s)
+	}
+	for _, m := range world.missiles {
+		state := MissileState{
+			IsDead:   m.IsDead,
+			Race:     m.race,
+			Pos:      m.pos,
+			Velocity: m.velocity,
+			Size:     m.size,
+			Color:    m.color,
+			Dir:      m.dir,
+			Accel:    m.accel,
+			EndSpeed: m.endSpeed,
+
+			Damage:       m.damage,
+			Pierce:       m.pierce,
+			Blast:        m.blast,
+			FriendlyFire: m.friendlyFire,
+
+			Homing:  m.homing,
+			Target:  sw.ship(m.target),
+			Fuel:    m.fuel,
+			SmokeCD: m.smokeCD,
+		}
+		for _, ship := range m.pierced {
+			state.Pierced = append(state.Pierced, sw.ship(ship))
+		}
+		snap.Missiles = append(snap.Missiles, state)
+	}
+	for _, o := range world.objects {
+		snap.Objects = append(snap.Objects, sw.object(o))
+	}
+
+	snap.Player = sw.ship(game.ship)
+	snap.Stars = sw.starfield(game.stars)
+	for i, stage := range game.stages {
+		snap.Stages = append(snap.Stages, sw.stage(stage))
+		if stage == game.stage {
+			snap.StageIndex = i
+		}
+	}
+	if game.stage != nil && snap.StageIndex < 0 {
+		stage := sw.stage(game.stage)
+		snap.Stage = &stage
+	}
+
+	// members are written last, they may be new ships
+	for i := 0; i < len(sw.formed); i++ {
+		for _, ship := range sw.formed[i].members {
+			member := sw.ship(ship) // may add formations
+			state := &snap.Formations[i]
+			state.Members = append(state.Members, member)
+		}
+	}
+
+	return snap
+}
+
+func (game *Game) Restore(snap *Snapshot) error {
+	if err := snap.checkModels(); err != nil {
+		return err
+	}
+
+	world := game.world
+	sr := &snapshotReader{snap: snap, world: world}
+
+	for _, state := range snap.Formations {
+		f := state.Formation.settings() // rewind keeps live members
+		f.time = state.Time
+		f.broken = state.Broken
+		sr.formations = append(sr.formations, &f)
+	}
+	for _, state := range snap.Ships {
+		sr.ships = append(sr.ships, sr.ship(state))
+	}
+	for i, state := range snap.Formations {
+		for _, member := range state.Members {
+			f := sr.formations[i]
+			f.members = append(f.members, sr.shipRef(member))
+		}
+	}
+	for _, state := range snap.Objects {
+		sr.objects = append(sr.objects, sr.object(state))
+	}
+
+	world.Seed = snap.Seed
+	world.randSrc.State = snap.Rand
+	world.fxRandSrc.State = snap.FxRand
+	world.TimeSpeed = snap.TimeSpeed
+	world.ships = append([]*Ship(nil), sr.ships[:snap.WorldShips]...)
+	world.objects = sr.objects
+	world.missiles = nil
+	for _, m := range snap.Missiles {
+		missile := &Missile{
+			IsDead:   m.IsDead,
+			race:     m.Race,
+			pos:      m.Pos,
+			velocity: m.Velocity,
+			size:     m.Size,
+			color:    m.Color,
+			dir:      m.Dir,
+			accel:    m.Accel,
+			endSpeed: m.EndSpeed,
+
+			damage:       m.Damage,
+			pierce:       m.Pierce,
+			blast:        m.Blast,
+			friendlyFire: m.FriendlyFire,
+
+			homing:  m.Homing,
+			target:  sr.shipRef(m.Target),
+			fuel:    m.Fuel,
+			smokeCD: m.SmokeCD,
+		}
+		for _, ship := range m.Pierced {
+			missile.pierced = append(missile.pierced, sr.shipRef(ship))
+		}
+		world.missiles = append(world.missiles, missile)
+	}
+
+	game.ship = sr.shipRef(snap.Player)
+	game.stars = sr.starfield(snap.Stars)
+	game.stages = nil
+	for _, state := range snap.Stages {
+		game.stages = append(game.stages, sr.stage(state))
+	}
+	game.stage = nil
+	if snap.StageIndex >= 0 {
+		game.stage = game.stages[snap.StageIndex]
+	} else if snap.Stage != nil {
+		game.stage = sr.stage(*snap.Stage)
+	}
+	game.nextStage = snap.NextStage
+	game.finished = snap.Finished
+	return nil
+}
+
+// checkModels finds ship models missing since the snapshot was taken, like
+// ones from another -models file.
+func (snap *Snapshot) checkModels() error {
+	var names []string
+	for _, ship := range snap.Ships {
+		names = append(names, ship.Model)
+	}
+	stages := snap.Stages
+	if snap.Stage != nil {
+		stages = append(stages[:len(stages):len(stages)], *snap.Stage)
+	}
+	for _, stage := range stages {
+		var spawns []SpawnDef
+		if final := stage.Final; final != nil && final.Boss != "" {
+			names = append(names, final.Boss) // empty is papa
+			for _, phase := range final.Phases {
+				spawns = append(spawns, phase.Escorts...)
+			}
+		}
+		if stage.Wave != nil {
+			spawns = append(spawns, stage.Wave.Spawns...)
+		}
+		for _, spawn := range spawns {
+			names = append(names, spawn.Models...)
+		}
+	}
+
+	for _, name := range names {
+		if _, found := ShipModels[name]; !found {
+			return fmt.Errorf("unknown ship model %q", name)
+		}
+	}
+	return nil
+}
+
+func (game *Game) SaveSnapshot(path string) error {
+	return SaveSnapshot(path, game.Snapshot())
+}
+
+func (game *Game) LoadSnapshot(path string) error {
+	snap, err := LoadSnapshot(path)
+	if err != nil {
+		return err
+	}
+	if err := game.Restore(snap); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	return nil
+}
+
+func SaveSnapshot(path string, snap *Snapshot) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	w.WriteString(snapshotMagic)
+	binary.Write(w, binary.LittleEndian, uint16(snapshotVersion))
+	if err := gob.NewEncoder(w).Encode(snap); err != nil {
+		return err
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func LoadSnapshot(path string) (*Snapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	magic := make([]byte, len(snapshotMagic))
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return nil, err
+	}
+	if string(magic) != snapshotMagic {
+		return nil, errors.New("not a snapshot file: " + path)
+	}
+
+	var version uint16
+	if err := binary.Read(r, binary.LittleEndian, &version); err != nil {
+		return nil, err
+	}
+	if version != snapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %d: %s",
+			version, path)
+	}
+
+	snap := &Snapshot{}
+	if err := gob.NewDecoder(r).Decode(snap); err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+func (sw *snapshotWriter) ship(s *Ship) int {
+	if s == nil {
+		return -1
+	}
+	if i, found := sw.ships[s]; found {
+		return i
+	}
+
+	i := len(sw.snap.Ships)
+	sw.ships[s] = i
+	sw.snap.Ships = append(sw.snap.Ships, ShipState{
+		Race:     s.Race,
+		IsDead:   s.IsDead,
+		Pos:      s.Pos,
+		Dir:      s.Dir,
+		Velocity: s.velocity,
+		Model:    ModelName(s.model),
+		Hp:       s.hp,
+		Damaged:  s.damaged,
+		TRS:      s.trs,
+		Fire:     s.fire,
+		Cooldown: append([]float32(nil), s.cooldown...),
+		Spin:     append([]float32(nil), s.spin...),
+		Turret:   append([]float32(nil), s.turret...),
+		EngineCD: append([]float32(nil), s.engineCD...),
+		Pilot:    sw.pilot(s.Pilot),
+		Parts:    partStates(s.parts),
+		GunsOn:   append([]bool(nil), s.gunsOn...),
+
+		Invulnerable: s.Invulnerable,
+	})
+	return i
+}
+
+func partStates(parts []shipPart) []PartState {
+	var states []PartState
+	for _, part := range parts {
+		states = append(states, PartState{
+			Hp:      part.hp,
+			Damaged: part.damaged,
+			Wrecked: part.wrecked,
+		})
+	}
+	return states
+}
+
+func (sw *snapshotWriter) object(object WorldObject) ObjectState {
+	switch o := object.(type) {
+	case *Particle:
+		return ObjectState{Particle: &ParticleState{
+			Velocity:    o.Velocity,
+			RenderGroup: o.RenderGroup,
+			Dead:        o.dead,
+			Pos:         o.pos,
+			StartSize:   o.startSize,
+			EndSize:     o.endSize,
+			Color:       o.color,
+			Lifetime:    o.lifetime,
+			TTL:         o.ttl,
+		}}
+	case Starfield:
+		sw.objects[o[0]] = len(sw.snap.Objects)
+		strata := make([]StratumState, len(o))
+		for i, ss := range o {
+			strata[i] = StratumState{
+				Size:        ss.size,
+				Stars:       append([]Star(nil), ss.stars...),
+				StripeCount: ss.stripeCount,
+				Speed:       ss.speed,
+				SpeedFactor: ss.speedFactor,
+				Color:       ss.color,
+				MinStarSize: ss.minStarSize,
+				MaxStarSize: ss.maxStarSize,
+				LastX:       ss.lastX,
+				LastStripe:  ss.lastStripe,
+			}
+		}
+		return ObjectState{Starfield: strata}
+	}
+	panic(fmt.Sprintf("can't snapshot world object %T", object))
+}
+
+func (sw *snapshotWriter) formation(f *Formation) int {
+	if i, found := sw.formations[f]; found {
+		return i
+	}
+
+	i := len(sw.snap.Formations)
+	sw.formations[f] = i
+	sw.formed = append(sw.formed, f)
+	sw.snap.Formations = append(sw.snap.Formations, FormationState{
+		Formation: f.settings(),
+		Time:      f.time,
+		Broken:    f.broken,
+	})
+	return i
+}
+
+func (sw *snapshotWriter) starfield(sf Starfield) int {
+	if i, found := sw.objects[sf[0]]; found {
+		return i
+	}
+	panic("starfield is not in the world")
+}
+
+func (sw *snapshotWriter) stage(stage Stage) StageState {
+	switch s := stage.(type) {
+	case *CargoStage:
+		cargo := *s
+		return StageState{Cargo: &cargo}
+	case *FighterStage:
+		return StageState{Fighter: true}
+	case *IntroStage:
+		return StageState{Intro: &IntroStageState{
+			Stars:     sw.starfield(s.Stars),
+			Ship:      sw.ship(s.Ship),
+			Time:      s.time,
+			TotalTime: s.totalTime,
+			Sound:     s.sound,
+		}}
+	case *OutroStage:
+		return StageState{Outro: &OutroStageState{
+			Stars:     sw.starfield(s.Stars),
+			Ship:      sw.ship(s.Ship),
+			Time:      s.time,
+			TotalTime: s.totalTime,
+			Started:   s.started,
+		}}
+	case *FinalStage:
+		state := &FinalStageState{
+			Boss:       s.Boss,
+			Phases:     s.Phases,
+			BossShip:   sw.ship(s.boss),
+			BossSound:  s.bossSound,
+			Phase:      s.phase,
+			Time:       s.time,
+			Transition: s.transition,
+		}
+		for _, ships := range s.escorts {
+			var escorts []int
+			for _, ship := range ships {
+				escorts = append(escorts, sw.ship(ship))
+			}
+			state.Escorts = append(state.Escorts, escorts)
+		}
+		return StageState{Final: state}
+	case *DeathStage:
+		return StageState{Death: &DeathStageState{
+			Ship:    sw.ship(s.Ship),
+			Time:    s.time,
+			Started: s.started,
+		}}
+	case *WaveStage:
+		state := &WaveStageState{
+			Spawns:    s.Spawns,
+			Until:     s.Until,
+			Time:      s.Time,
+			T:         s.time,
+			NextSpawn: s.nextSpawn,
+		}
+		for _, ship := range s.ships {
+			state.Ships = append(state.Ships, sw.ship(ship))
+		}
+		return StageState{Wave: state}
+	}
+	panic(fmt.Sprintf("can't snapshot stage %T", stage))
+}
+
+func (sw *snapshotWriter) pilot(pilot Pilot) *PilotState {
+	switch p := pilot.(type) {
+	case nil:
+		return nil
+	case *Advancer:
+		advancer := *p
+		return &PilotState{Advancer: &advancer}
+	case *RoundShooter:
+		return &PilotState{Round: &RoundShooterState{
+			EndPosX:  p.endPosX,
+			AngleMin: p.angleMin,
+			AngleMax: p.angleMax,
+			Dir:      p.dir,
+			T:        p.t,
+		}}
+	case *SteeringPilot:
+		state := &SteeringPilotState{Fire: p.Fire}
+		if p.Move != nil {
+			move := sw.steering(p.Move)
+			state.Move = &move
+		}
+		if p.Aim != nil {
+			aim := *p.Aim
+			state.Aim = &aim
+		}
+		return &PilotState{Steering: state}
+	case *PathFollower:
+		return &PilotState{Path: &PathFollowerState{
+			Path:    p.Path.Def,
+			Speed:   p.Speed,
+			Mirror:  p.Mirror,
+			Start:   p.start,
+			Dist:    p.dist,
+			Started: p.started,
+		}}
+	case *FormationPilot:
+		return &PilotState{Formed: &FormationPilotState{
+			Formation: sw.formation(p.Formation),
+			Attack:    sw.pilot(p.Attack),
+		}}
+	}
+	panic(fmt.Sprintf("can't snapshot pilot %T", pilot))
+}
+
+func (sw *snapshotWriter) steering(steering Steering) SteeringState {
+	switch s := steering.(type) {
+	case *Seek:
+		seek := *s
+		return SteeringState{Seek: &seek}
+	case *Flee:
+		flee := *s
+		return SteeringState{Flee: &flee}
+	case *Arrive:
+		arrive := *s
+		return SteeringState{Arrive: &arrive}
+	case *Strafe:
+		return SteeringState{Strafe: &StrafeState{
+			Target: s.Target,
+			Period: s.Period,
+			T:      s.t,
+		}}
+	case *Orbit:
+		orbit := *s
+		return SteeringState{Orbit: &orbit}
+	case Blend:
+		state := SteeringState{Blend: []WeightedState{}}
+		for _, w := range s {
+			state.Blend = append(state.Blend, WeightedState{
+				Steering: sw.steering(w.Steering),
+				Weight:   w.Weight,
+			})
+		}
+		return state
+	}
+	panic(fmt.Sprintf("can't snapshot steering %T", steering))
+}
+
+func (sr *snapshotReader) ship(state ShipState) *Ship {
+	model := ShipModels[state.Model] // checked by Restore
+
+	ship := &Ship{
+		Race:     state.Race,
+		IsDead:   state.IsDead,
+		Pos:      state.Pos,
+		Dir:      state.Dir,
+		velocity: state.Velocity,
+		model:    model,
+		hp:       state.Hp,
+		damaged:  state.Damaged,
+		trs:      state.TRS,
+		fire:     state.Fire,
+		cooldown: append([]float32(nil), state.Cooldown...),
+		spin:     append([]float32(nil), state.Spin...),
+		turret:   append([]float32(nil), state.Turret...),
+		engineCD: append([]float32(nil), state.EngineCD...),
+		Pilot:    sr.pilot(state.Pilot),
+		gunsOn:   append([]bool(nil), state.GunsOn...),
+
+		Invulnerable: state.Invulnerable,
+	}
+	for _, part := range state.Parts {
+		ship.parts = append(ship.parts, shipPart{
+			hp:      part.Hp,
+			damaged: part.Damaged,
+			wrecked: part.Wrecked,
+		})
+	}
+	ship.syncModel()
+	return ship
+}
+
+func (sr *snapshotReader) shipRef(i int) *Ship {
+	if i < 0 {
+		return nil
+	}
+	return sr.ships[i]
+}
+
+func (sr *snapshotReader) object(state ObjectState) WorldObject {
+	if p := state.Particle; p != nil {
+		return &Particle{
+			Velocity:    p.Velocity,
+			RenderGroup: p.RenderGroup,
+			dead:        p.Dead,
+			pos:         p.Pos,
+			startSize:   p.StartSize,
+			endSize:     p.EndSize,
+			color:       p.Color,
+			lifetime:    p.Lifetime,
+			ttl:         p.TTL,
+		}
+	}
+
+	sf := make(Starfield, len(state.Starfield))
+	for i, ss := range state.Starfield {
+		sf[i] = &StarStratum{
+			size:        ss.Size,
+			stars:       append([]Star(nil), ss.Stars...),
+			stripeCount: ss.StripeCount,
+			speed:       ss.Speed,
+			speedFactor: ss.SpeedFactor,
+			color:       ss.Color,
+			minStarSize: ss.MinStarSize,
+			maxStarSize: ss.MaxStarSize,
+			lastX:       ss.LastX,
+			lastStripe:  ss.LastStripe,
+			rand:        sr.world.FxRand,
+		}
+	}
+	return sf
+}
+
+func (sr *snapshotReader) starfield(i int) Starfield {
+	return sr.objects[i].(Starfield)
+}
+
+func (sr *snapshotReader) stage(state StageState) Stage {
+	switch {
+	case state.Cargo != nil:
+		cargo := *state.Cargo
+		return &cargo
+	case state.Fighter:
+		return &FighterStage{}
+	case state.Intro != nil:
+		return &IntroStage{
+			Stars:     sr.starfield(state.Intro.Stars),
+			Ship:      sr.shipRef(state.Intro.Ship),
+			time:      state.Intro.Time,
+			totalTime: state.Intro.TotalTime,
+			sound:     state.Intro.Sound,
+		}
+	case state.Outro != nil:
+		return &OutroStage{
+			Stars:     sr.starfield(state.Outro.Stars),
+			Ship:      sr.shipRef(state.Outro.Ship),
+			time:      state.Outro.Time,
+			totalTime: state.Outro.TotalTime,
+			started:   state.Outro.Started,
+		}
+	case state.Final != nil:
+		stage := &FinalStage{
+			Boss:       state.Final.Boss,
+			Phases:     state.Final.Phases,
+			boss:       sr.shipRef(state.Final.BossShip),
+			bossSound:  state.Final.BossSound,
+			phase:      state.Final.Phase,
+			time:       state.Final.Time,
+			transition: state.Final.Transition,
+		}
+		for _, escorts := range state.Final.Escorts {
+			var ships []*Ship
+			for _, i := range escorts {
+				ships = append(ships, sr.shipRef(i))
+			}
+			stage.escorts = append(stage.escorts, ships)
+		}
+		return stage
+	case state.Death != nil:
+		return &DeathStage{
+			Ship:    sr.shipRef(state.Death.Ship),
+			time:    state.Death.Time,
+			started: state.Death.Started,
+		}
+	case state.Wave != nil:
+		stage := &WaveStage{
+			Spawns:    state.Wave.Spawns,
+			Until:     state.Wave.Until,
+			Time:      state.Wave.Time,
+			time:      state.Wave.T,
+			nextSpawn: state.Wave.NextSpawn,
+		}
+		for _, i := range state.Wave.Ships {
+			stage.ships = append(stage.ships, sr.shipRef(i))
+		}
+		return stage
+	}
+	panic("empty stage state")
+}
+
+func (sr *snapshotReader) pilot(state *PilotState) Pilot {
+	switch {
+	case state == nil:
+		return nil
+	case state.Advancer != nil:
+		advancer := *state.Advancer
+		return &advancer
+	case state.Round != nil:
+		return &RoundShooter{
+			endPosX:  state.Round.EndPosX,
+			angleMin: state.Round.AngleMin,
+			angleMax: state.Round.AngleMax,
+			dir:      state.Round.Dir,
+			t:        state.Round.T,
+		}
+	case state.Steering != nil:
+		pilot := &SteeringPilot{Fire: state.Steering.Fire}
+		if state.Steering.Move != nil {
+			pilot.Move = sr.steering(*state.Steering.Move)
+		}
+		if state.Steering.Aim != nil {
+			aim := *state.Steering.Aim
+			pilot.Aim = &aim
+		}
+		return pilot
+	case state.Path != nil:
+		return &PathFollower{
+			Path:    NewPath(state.Path.Path, sr.world.Size),
+			Speed:   state.Path.Speed,
+			Mirror:  state.Path.Mirror,
+			start:   state.Path.Start,
+			dist:    state.Path.Dist,
+			started: state.Path.Started,
+		}
+	case state.Formed != nil:
+		return &FormationPilot{
+			Formation: sr.formations[state.Formed.Formation],
+			Attack:    sr.pilot(state.Formed.Attack),
+		}
+	}
+	panic("empty pilot state")
+}
+
+func (sr *snapshotReader) steering(state SteeringState) Steering {
+	switch {
+	case state.Seek != nil:
+		seek := *state.Seek
+		return &seek
+	case state.Flee != nil:
+		flee := *state.Flee
+		return &flee
+	case state.Arrive != nil:
+		arrive := *state.Arrive
+		return &arrive
+	case state.Strafe != nil:
+		return &Strafe{
+			Target: state.Strafe.Target,
+			Period: state.Strafe.Period,
+			t:      state.Strafe.T,
+		}
+	case state.Orbit != nil:
+		orbit := *state.Orbit
+		return &orbit
+	case state.Blend != nil:
+		blend := Blend{}
+		for _, w := range state.Blend {
+			blend = append(blend, Weighted{
+				Steering: sr.steering(w.Steering),
+				Weight:   w.Weight,
+			})
+		}
+		return blend
+	}
+	panic("empty steering state")
+}
@@ -1,3 +1,6 @@
+//go:build !headless
+// +build !headless
+
 package main
 
 import (
@@ -9,6 +12,8 @@ import (
 	"github.com/Jragonmiris/go-al/decoder/wav"
 )
 
+type ALAudio struct{}
+
 var soundData struct {
 	device  alc.Device
 	context alc.Context
@@ -104,3 +109,7 @@ func PlaySound(label string, gain, pitch float32) {
 	source.SetPitch(pitch)
 	PanicOnError(source.Play())
 }
+
+func (ALAudio) Play(label string, gain, pitch float32) {
+	PlaySound(label, gain, pitch)
+}
@@ -0,0 +1,104 @@
+package main
+
+import mgl "github.com/go-gl/mathgl/mgl32"
+
+// ShipGrid is a uniform grid broadphase over the world rectangle. Anything
+// outside of the world is kept in the border cells.
+type ShipGrid struct {
+	cellSize float32
+	cols     int
+	rows     int
+	cells    [][]int
+
+	ships  []*Ship
+	stamps []int
+	stamp  int
+	found  []int
+	result []*Ship
+}
+
+func NewShipGrid(size mgl.Vec2, cellSize float32) *ShipGrid {
+	cols := int(size.X()/cellSize) + 1
+	rows := int(size.Y()/cellSize) + 1
+	return &ShipGrid{
+		cellSize: cellSize,
+		cols:     cols,
+		rows:     rows,
+		cells:    make([][]int, cols*rows),
+	}
+}
+
+// Build puts ships into the grid. Every ship takes space for the distance
+// it can fly in lookahead seconds, so the grid stays valid while ships move.
+func (g *ShipGrid) Build(ships []*Ship, lookahead float32) {
+	for i := range g.cells {
+		g.cells[i] = g.cells[i][:0]
+	}
+	g.ships = ships
+	for len(g.stamps) < len(ships) {
+		g.stamps = append(g.stamps, 0)
+	}
+
+	for i, s := range ships {
+		pad := s.model.Speed * lookahead
+		aabb := s.AABB().Add(mgl.Vec4{-pad, -pad, pad, pad})
+		minX, minY, maxX, maxY := g.cellRange(aabb)
+		for y := minY; y <= maxY; y++ {
+			for x := minX; x <= maxX; x++ {
+				cell := y*g.cols + x
+				g.cells[cell] = append(g.cells[cell], i)
+			}
+		}
+	}
+}
+
+// Query returns ships that may intersect with aabb, in the order they were
+// passed to Build. The result is reused, it is valid until the next
+// Query.
+func (g *ShipGrid) Query(aabb mgl.Vec4) []*Ship {
+	g.stamp += 1
+	g.found = g.found[:0]
+
+	minX, minY, maxX, maxY := g.cellRange(aabb)
+	for y := minY; y <= maxY; y++ {
+		for x := minX; x <= maxX; x++ {
+			for _, i := range g.cells[y*g.cols+x] {
+				if g.stamps[i] != g.stamp {
+					g.stamps[i] = g.stamp
+					g.found = append(g.found, i)
+				}
+			}
+		}
+	}
+
+	for i := 1; i < len(g.found); i++ { // insertion sort, there are few
+		for j := i; j > 0 && g.found[j-1] > g.found[j]; j-- {
+			g.found[j-1], g.found[j] = g.found[j], g.found[j-1]
+		}
+	}
+
+	g.result = g.result[:0]
+	for _, i := range g.found {
+		g.result = append(g.result, g.ships[i])
+	}
+	return g.result
+}
+
+func (g *ShipGrid) cellRange(aabb mgl.Vec4) (minX, minY, maxX, maxY int) {
+	minX = g.cell(aabb[0], g.cols)
+	minY = g.cell(aabb[1], g.rows)
+	maxX = g.cell(aabb[2], g.cols)
+	maxY = g.cell(aabb[3], g.rows)
+	return
+}
+
+func (g *ShipGrid) cell(coord float32, count int) int {
+	c := int(coord / g.cellSize)
+	if coord < 0 {
+		c = 0
+	}
+	if c >= count {
+		c = count - 1
+	}
+	return c
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+)
+
+func TestShipGrid(t *testing.T) {
+	small := &ShipModel{Size: mgl.Vec2{20, 20}, Speed: 100}
+	big := &ShipModel{Size: mgl.Vec2{350, 350}}
+	ship := func(model *ShipModel, x, y float32) *Ship {
+		return &Ship{model: model, Pos: mgl.Vec2{x, y}}
+	}
+	point := func(x, y float32) mgl.Vec4 {
+		return mgl.Vec4{x, y, x + 1, y + 1}
+	}
+
+	edge := ship(small, 95, 50)      // cells 0 and 1
+	touching := ship(small, 190, 50) // right side on the cell 2 edge
+	large := ship(big, 500, 300)     // cells 3 to 6
+	fast := ship(small, 50, 450)     // 100 px more with lookahead
+	lost := ship(small, -500, 5000)  // kept in a border cell
+	ships := []*Ship{edge, touching, large, fast, lost}
+
+	grid := NewShipGrid(mgl.Vec2{1000, 600}, 100)
+	tests := []struct {
+		lookahead float32
+		aabb      mgl.Vec4
+		ship      *Ship
+		found     bool
+	}{
+		{0, point(10, 10), edge, true},
+		{0, point(150, 10), edge, true},
+		{0, point(250, 10), edge, false},
+		{0, point(200, 10), touching, true},
+		{0, point(250, 10), touching, true},
+		{0, point(350, 10), touching, false},
+		{0, point(480, 280), large, true},
+		{0, point(310, 130), large, true},
+		{0, point(690, 470), large, true},
+		{0, point(750, 300), large, false},
+		{0, point(250, 300), large, false},
+		{0, point(150, 450), fast, false},
+		{1, point(150, 450), fast, true},
+		{1, point(150, 350), fast, true},
+		{1, point(250, 450), fast, false},
+		{0, point(50, 650), lost, true},
+		{0, point(150, 650), lost, false},
+	}
+	for _, test := range tests {
+		grid.Build(ships, test.lookahead)
+		found := false
+		for _, s := range grid.Query(test.aabb) {
+			found = found || s == test.ship
+		}
+		if found != test.found {
+			t.Errorf("ship at %v, lookahead %v: query %v found it: %v",
+				test.ship.Pos, test.lookahead, test.aabb, found)
+		}
+	}
+
+	grid.Build(ships, 0)
+	all := grid.Query(mgl.Vec4{-100, -100, 2000, 2000})
+	if len(all) != len(ships) {
+		t.Fatalf("query of the world found %d ships, want %d", len(all),
+			len(ships))
+	}
+	for i := range all {
+		if all[i] != ships[i] {
+			t.Errorf("ship %d is out of order", i)
+		}
+	}
+}
+
+// BenchmarkWorldUpdate runs a hundred ships firing thousands of missiles.
+// A 60 fps frame runs StepRate/60 steps within 16.6 ms, frame-% is the
+// part of it the simulation takes.
+func BenchmarkWorldUpdate(b *testing.B) {
+	const frameBudget = float64(time.Second) / 60
+
+	world := busyWorld(b, 50)
+	for i := 0; i < StepRate*5; i++ {
+		world.Update(StepDT)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	start := time.Now()
+	for i := 0; i < b.N; i++ {
+		world.Update(StepDT)
+	}
+	step := float64(time.Since(start)) / float64(b.N)
+
+	b.ReportMetric(float64(len(world.ships)), "ships")
+	b.ReportMetric(float64(len(world.missiles)), "missiles")
+	b.ReportMetric(100*step*StepRate/60/frameBudget, "frame-%")
+}
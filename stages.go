@@ -1,19 +1,15 @@
 package main
 
-import (
-	"math/rand"
-
-	mgl "github.com/go-gl/mathgl/mgl32"
-)
+import mgl "github.com/go-gl/mathgl/mgl32"
 
+// CargoStage always sends MinCount ships. A MaxCount above it only takes
+// a random number like the classic stage did, so old replays still match.
 type CargoStage struct {
 	MinCount int
 	MaxCount int
 }
 
-type FighterStage struct {
-	ships []*Ship
-}
+type FighterStage struct{}
 
 type IntroStage struct {
 	Stars Starfield
@@ -33,11 +29,27 @@ type OutroStage struct {
 	started   bool
 }
 
+// FinalStage is a boss fight in phases, the boss is papa by default.
 type FinalStage struct {
-	papa      *Ship
-	papaSound bool
-	upPilot   *RoundShooter
-	downPilot *RoundShooter
+	Boss   string     // ship model
+	Phases []PhaseDef // the first one starts right away
+
+	boss       *Ship
+	bossSound  bool
+	phase      int
+	time       float32 // since the phase start
+	transition float32 // seconds left of the window before the phase
+	escorts    [][]*Ship
+}
+
+type WaveStage struct {
+	Spawns []SpawnDef
+	Until  string
+	Time   float32
+
+	time      float32
+	nextSpawn int
+	ships     []*Ship
 }
 
 type DeathStage struct {
@@ -54,15 +66,15 @@ func (s *CargoStage) Init(world *World) {
 	models := []*ShipModel{&CargoModel, &CargoModel2}
 	count := s.MinCount
 	if s.MaxCount > s.MinCount {
-		rand.Intn(s.MaxCount - s.MinCount)
+		world.Rand.Intn(s.MaxCount - s.MinCount)
 	}
 	for i := 0; i < count; i++ {
 		posY := float32(i+1) / (float32(count) + 1)
-		speed := minSpeed + rand.Float32()*(maxSpeed-minSpeed)
+		speed := minSpeed + world.Rand.Float32()*(maxSpeed-minSpeed)
 
-		ship := NewShip(Others, models[rand.Intn(len(models))])
+		ship := NewShip(Others, models[world.Rand.Intn(len(models))])
 		ship.Pos = mgl.Vec2{world.Size.X() * posX, world.Size.Y() * posY}
-		ship.Control(mgl.Vec2{-speed, 0}, false)
+		ship.Pilot = &Advancer{Speed: speed}
 		world.AddShips(ship)
 	}
 }
@@ -73,99 +85,248 @@ func (s *CargoStage) Update(dt float32, world *World) bool {
 
 func (s *FighterStage) Init(world *World) {
 	const count = 8
-	const minSpeed = 0.1
-	const maxSpeed = 0.5
+	const speed = 0.3
 	const posX = 1.1
+	const stopX = 0.9
+	const spacing = 60
+	const breakTime = 20
+	const breakCount = 3
+	const seekWeight = 0.4
+	const turnRate = 90
+	const fireAngle = 10
+
+	ships := make([]*Ship, count)
+	for i := range ships {
+		ships[i] = NewShip(Others, &FighterModel)
+		world.AddShips(ships[i])
+	}
 
-	s.ships = make([]*Ship, count)
-	for i := 0; i < count; i++ {
-		pos := float32(i+1) / (float32(count) + 1)
-		speed := minSpeed + rand.Float32()*(maxSpeed-minSpeed)
+	formation := &Formation{
+		Shape:      "wedge",
+		Spacing:    spacing,
+		Pos:        mgl.Vec2{world.Size.X() * posX, world.Size.Y() / 2},
+		Speed:      speed * FighterModel.Speed,
+		StopX:      stopX,
+		BreakTime:  breakTime,
+		BreakCount: breakCount,
+	}
+	formation.Form(ships, func(ship *Ship) Pilot {
+		return &SteeringPilot{
+			Move: Blend{{&Seek{Target{Player: true}}, seekWeight}},
+			Aim: &AimAtPlayer{
+				TurnRate:  mgl.DegToRad(turnRate),
+				FireAngle: mgl.DegToRad(fireAngle),
+			},
+		}
+	})
+}
 
-		ship := NewShip(Others, &FighterModel)
-		ship.Pos = mgl.Vec2{world.Size.X() * posX, world.Size.Y() * pos}
-		ship.Control(mgl.Vec2{-speed, 0}, false)
+func (s *FighterStage) Update(dt float32, world *World) bool {
+	return world.ShipCount() > 1
+}
 
-		world.AddShips(ship)
-		s.ships[i] = ship
+func (s *FinalStage) Init(world *World) {
+	const posX = 1.1
+
+	if s.Boss == "" {
+		PanicOnError(s.normalize(nil)) // not built from a level
 	}
+
+	s.bossSound = false
+	s.boss = NewShip(Others, ShipModels[s.Boss])
+	s.boss.Pos = mgl.Vec2{world.Size.X() * posX, world.Size.Y() / 2}
+	world.AddShips(s.boss)
+
+	s.startPhase(world, 0)
 }
 
-func (s *FighterStage) Update(dt float32, world *World) bool {
-	const posX = 0.9
+func (s *FinalStage) Update(dt float32, world *World) bool {
+	if !s.bossSound && s.boss.Pos.X() <= world.Size.X() {
+		s.bossSound = true
+		world.PlaySound("papa", 1, 0.8)
+	}
 
-	if world.ShipCount() <= 1 {
-		s.ships = []*Ship{}
+	if s.boss.IsDead {
+		s.killEscorts()
 		return false
 	}
 
-	for _, s := range s.ships {
-		if s.Pos.X() <= world.Size.X()*posX {
-			s.Control(mgl.Vec2{}, true)
+	if s.transition > 0 {
+		s.transition -= dt
+		if s.transition <= 0 {
+			s.beginPhase(world)
 		}
+		return true
 	}
 
+	s.time += dt
+	if next := s.phase + 1; next < len(s.Phases) && s.triggered(next) {
+		s.startPhase(world, next)
+		return true
+	}
+
+	phase := &s.Phases[s.phase]
+	for i, ships := range s.escorts {
+		if phase.Respawn && allDead(ships) {
+			s.escorts[i] = spawnShips(world, phase.Escorts[i])
+		}
+	}
 	return true
 }
 
-func (s *FinalStage) Init(world *World) {
-	const posX = 1.1
-	const papaSpeed = 0.05
+func (s *FinalStage) triggered(phase int) bool {
+	def := &s.Phases[phase]
+	switch {
+	case def.Health > 0 && s.boss.Health() <= def.Health:
+		return true
+	case def.Time > 0 && s.time >= def.Time:
+		return true
+	case def.Part != "":
+		for i, part := range s.boss.model.Parts {
+			if part.Name == def.Part && s.boss.parts[i].wrecked {
+				return true
+			}
+		}
+	}
+	return false
+}
 
-	s.papaSound = false
-	s.papa = NewShip(Others, &PapaModel)
-	s.papa.Pos = mgl.Vec2{world.Size.X() * posX, world.Size.Y() / 2}
-	s.papa.Control(mgl.Vec2{-papaSpeed, 0}, false)
-	world.AddShips(s.papa)
+// startPhase opens the invulnerable window before the phase, if it has
+// one.
+func (s *FinalStage) startPhase(world *World, phase int) {
+	const flashTTL = 0.5
 
-	s.upPilot = nil
-	s.downPilot = nil
+	s.killEscorts()
+	s.phase = phase
+	s.time = 0
+	s.transition = s.Phases[phase].Transition
+	if s.transition <= 0 {
+		s.beginPhase(world)
+		return
+	}
+
+	s.boss.Invulnerable = true
+	s.boss.Pilot = nil
+	s.boss.Control(mgl.Vec2{}, false)
+
+	size := Max(s.boss.model.Size.Elem()) * 2
+	world.AddObjects(world.NewParticle(s.boss.Pos, size, 0, flashTTL,
+		WhiteColor))
+	world.PlaySound("papa", 1, 1+0.2*float32(phase))
 }
-func (s *FinalStage) Update(dt float32, world *World) bool {
-	const papaPosX = 0.9
 
-	if !s.papaSound && s.papa.Pos.X() <= world.Size.X() {
-		s.papaSound = true
-		PlaySound("papa", 1, 0.8)
+func (s *FinalStage) beginPhase(world *World) {
+	def := &s.Phases[s.phase]
+
+	s.transition = 0
+	s.boss.Invulnerable = false
+	s.boss.Pilot = def.Pilot.NewPilot(s.boss, def.Speed, world)
+	s.boss.SelectGuns(def.Guns)
+
+	s.escorts = make([][]*Ship, len(def.Escorts))
+	for i, spawn := range def.Escorts {
+		s.escorts[i] = spawnShips(world, spawn)
 	}
+}
 
-	if s.papa.Pos.X() <= world.Size.X()*papaPosX {
-		s.papa.Control(mgl.Vec2{}, true)
+func (s *FinalStage) killEscorts() {
+	for _, ships := range s.escorts {
+		for _, ship := range ships {
+			ship.Kill()
+		}
 	}
+	s.escorts = nil
+}
 
-	if s.upPilot == nil || s.upPilot.IsDead {
-		s.upPilot = s.spawnShip(world, 0.9, 0, mgl.DegToRad(70))
-	} else {
-		s.upPilot.Update(dt, world)
+// papaPhases is the classic fight: papa comes in, stops and fires with
+// two escorts called again when they are dead.
+func papaPhases() []PhaseDef {
+	escort := func(posY, angle float32) SpawnDef {
+		return SpawnDef{
+			Models: []string{"shooter"},
+			Y:      [2]float32{posY, posY},
+			Pilot: PilotDef{
+				Type:     "round",
+				StopX:    0.9,
+				AngleMax: angle,
+			},
+		}
 	}
 
-	if s.downPilot == nil || s.downPilot.IsDead {
-		s.downPilot = s.spawnShip(world, 0.1, 0, mgl.DegToRad(-70))
-	} else {
-		s.downPilot.Update(dt, world)
+	return []PhaseDef{{
+		Pilot:   PilotDef{Type: "stop", StopX: 0.9},
+		Speed:   0.05,
+		Escorts: []SpawnDef{escort(0.9, 70), escort(0.1, -70)},
+		Respawn: true,
+	}}
+}
+
+func allDead(ships []*Ship) bool {
+	for _, ship := range ships {
+		if !ship.IsDead {
+			return false
+		}
 	}
+	return true
+}
 
-	if s.papa.IsDead {
-		s.upPilot.Die()
-		s.downPilot.Die()
-		return false
+func (s *WaveStage) Init(world *World) {
+	s.time = 0
+	s.nextSpawn = 0
+	s.ships = nil
+}
+
+func (s *WaveStage) Update(dt float32, world *World) bool {
+	s.time += dt
+	for s.nextSpawn < len(s.Spawns) && s.Spawns[s.nextSpawn].Delay <= s.time {
+		s.spawn(world, s.nextSpawn)
+		s.nextSpawn += 1
 	}
 
-	return true
+	alive := false
+	for _, ship := range s.ships {
+		alive = alive || !ship.IsDead
+	}
+
+	if s.Until == "time" {
+		return s.time < s.Time
+	}
+	return alive || s.nextSpawn < len(s.Spawns)
 }
 
-func (s *FinalStage) spawnShip(world *World, posY, angleMin,
-	angleMax float32) *RoundShooter {
+func (s *WaveStage) spawn(world *World, index int) {
+	ships := spawnShips(world, s.Spawns[index])
+	s.ships = append(s.ships, ships...)
+}
 
-	const posX = 1.1
-	const endPosX = 0.9
+// spawnShips adds ships of a normalized spawn to the world.
+func spawnShips(world *World, spawn SpawnDef) []*Ship {
+	count := spawn.Count
+	if spawn.MaxCount > spawn.Count {
+		count += world.Rand.Intn(spawn.MaxCount - spawn.Count + 1)
+	}
+
+	minY, maxY := spawn.Y[0], spawn.Y[1]
+	minSpeed, maxSpeed := spawn.Speed[0], spawn.Speed[1]
+	ships := make([]*Ship, count)
+	for i := range ships {
+		posY := minY + (maxY-minY)*float32(i+1)/(float32(count)+1)
+		speed := minSpeed + world.Rand.Float32()*(maxSpeed-minSpeed)
+		model := ShipModels[spawn.Models[world.Rand.Intn(len(spawn.Models))]]
+
+		ship := NewShip(Others, model)
+		ship.Pos = mgl.Vec2{world.Size.X() * spawn.X, world.Size.Y() * posY}
+		ship.Pilot = spawn.Pilot.NewPilot(ship, speed, world)
+		ships[i] = ship
+	}
 
-	ship := NewShip(Others, &ShooterModel)
-	ship.Pos = mgl.Vec2{world.Size.X() * posX, world.Size.Y() * posY}
-	pilot := NewRoundShooter(ship, endPosX, angleMin, angleMax)
-	world.AddShips(ship)
+	if spawn.Formation != nil {
+		formation := spawn.Formation.build(ships, minSpeed, world)
+		formation.Form(ships, func(ship *Ship) Pilot { return ship.Pilot })
+	}
 
-	return pilot
+	world.AddShips(ships...)
+	return ships
 }
 
 func (s *IntroStage) Init(world *World) {
@@ -190,7 +351,7 @@ func (s *IntroStage) Update(dt float32, world *World) bool {
 		s.Ship.Race = Human
 	} else if s.Ship.Pos.X() > 0 && !s.sound {
 		s.sound = true
-		PlaySound("intro", 1, 1)
+		world.PlaySound("intro", 1, 1)
 	}
 
 	s.time += dt
@@ -240,7 +401,7 @@ func (s *OutroStage) Update(dt float32, world *World) bool {
 		} else {
 			s.started = true
 			s.Ship.Control(mgl.Vec2{speed, 0}, false)
-			PlaySound("blip", 1, 1)
+			world.PlaySound("blip", 1, 1)
 		}
 	}
 
@@ -265,14 +426,14 @@ func (s *DeathStage) Update(dt float32, world *World) bool {
 	s.time -= dt
 	if !s.started && s.time < finalTime {
 		s.started = true
-		world.AddObjects(NewParticle(
+		world.AddObjects(world.NewParticle(
 			s.Ship.Pos,
 			0,
 			Max(world.Size.Elem())*sizeBoost,
 			finalTime,
 			BlackColor,
 		))
-		PlaySound("blip", 1, 1)
+		world.PlaySound("blip", 1, 1)
 	}
 
 	if s.time < 0 {
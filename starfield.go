@@ -27,11 +27,12 @@ type StarStratum struct {
 
 	lastX      float32
 	lastStripe int
+	rand       *rand.Rand
 }
 
 type Starfield []*StarStratum
 
-func NewStarfield(width, height float32) Starfield {
+func NewStarfield(width, height float32, rnd *rand.Rand) Starfield {
 	const count = 20
 	const stars = 50
 	const minSpeed = 5
@@ -45,7 +46,7 @@ func NewStarfield(width, height float32) Starfield {
 	for i := range sf {
 		speed := minSpeed + (maxSpeed-minSpeed)*float32(i)/count
 		color := BlendColors(minColor, maxColor, float32(i)/count)
-		sf[i] = NewStarStratum(size, stars, speed, color)
+		sf[i] = NewStarStratum(size, stars, speed, color, rnd)
 	}
 	return sf
 }
@@ -56,7 +57,7 @@ func (sf Starfield) Update(dt float32) {
 	}
 }
 
-func (sf Starfield) Draw(renderer *Renderer) {
+func (sf Starfield) Draw(renderer Renderer) {
 	for i := range sf {
 		sf[i].Draw(renderer)
 	}
@@ -73,7 +74,7 @@ func (sf Starfield) ChangeSpeed(factor float32) {
 }
 
 func NewStarStratum(size mgl.Vec2, count int, speed float32,
-	color mgl.Vec4) *StarStratum {
+	color mgl.Vec4, rnd *rand.Rand) *StarStratum {
 
 	const extraStripes = 2
 
@@ -85,6 +86,7 @@ func NewStarStratum(size mgl.Vec2, count int, speed float32,
 		speed:       speed,
 		speedFactor: 1,
 		color:       color,
+		rand:        rnd,
 	}
 
 	for i := 0; i < stripeCount+extraStripes; i++ {
@@ -108,7 +110,7 @@ func (ss *StarStratum) Update(dt float32) {
 	}
 }
 
-func (ss *StarStratum) Draw(renderer *Renderer) {
+func (ss *StarStratum) Draw(renderer Renderer) {
 	model := make([]mgl.Vec2, len(StarModel))
 
 	for _, star := range ss.stars {
@@ -135,10 +137,10 @@ func (ss *StarStratum) generateNextStripe() {
 
 	starStripe := ss.starStripe(ss.lastStripe)
 	for i := 0; i < len(starStripe); i++ {
-		starStripe[i].Pos[0] = ss.lastX + width*rand.Float32()
-		starStripe[i].Pos[1] = height*float32(i) + height*rand.Float32()
+		starStripe[i].Pos[0] = ss.lastX + width*ss.rand.Float32()
+		starStripe[i].Pos[1] = height*float32(i) + height*ss.rand.Float32()
 
-		size := StarMinSize + (StarMaxSize-StarMinSize)*rand.Float32()
+		size := StarMinSize + (StarMaxSize-StarMinSize)*ss.rand.Float32()
 		starStripe[i].Size = size
 	}
 
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"math"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+)
+
+// Steering is a movement behaviour. Steer returns thrust for Ship.Control,
+// a direction scaled by the fraction of the ship speed to use.
+type Steering interface {
+	Steer(dt float32, ship *Ship, world *World) mgl.Vec2
+}
+
+// Target is a point to steer to. Player targets follow the nearest player
+// ship and fall back to Pos when there is none.
+type Target struct {
+	Pos    mgl.Vec2 // fraction of the world size
+	Player bool
+}
+
+// Seek flies to the target at full speed.
+type Seek struct {
+	Target Target
+}
+
+// Flee flies away from the target while it is closer than Radius pixels,
+// or always if Radius is 0.
+type Flee struct {
+	Target Target
+	Radius float32
+}
+
+// Arrive flies to the target and slows down within Radius pixels.
+type Arrive struct {
+	Target Target
+	Radius float32
+}
+
+// Strafe flies sideways to the target and turns back every Period seconds.
+type Strafe struct {
+	Target Target
+	Period float32
+
+	t float32
+}
+
+// Orbit circles around the target at Radius pixels.
+type Orbit struct {
+	Target    Target
+	Radius    float32
+	Clockwise bool
+}
+
+// Blend sums weighted behaviours, the result is limited to full speed.
+type Blend []Weighted
+
+type Weighted struct {
+	Steering Steering
+	Weight   float32
+}
+
+// AimAtPlayer turns the ship to the nearest player ship at TurnRate radians
+// per second and fires while it is within FireAngle of the player.
+type AimAtPlayer struct {
+	TurnRate  float32
+	FireAngle float32
+}
+
+func (t Target) Position(world *World, from mgl.Vec2) mgl.Vec2 {
+	if t.Player {
+		if player := world.NearestShip(from, Human, Autopilot); player != nil {
+			return player.Pos
+		}
+	}
+	return mgl.Vec2{t.Pos.X() * world.Size.X(), t.Pos.Y() * world.Size.Y()}
+}
+
+func (s *Seek) Steer(dt float32, ship *Ship, world *World) mgl.Vec2 {
+	return direction(ship.Pos, s.Target.Position(world, ship.Pos))
+}
+
+func (s *Flee) Steer(dt float32, ship *Ship, world *World) mgl.Vec2 {
+	target := s.Target.Position(world, ship.Pos)
+	if s.Radius > 0 && target.Sub(ship.Pos).Len() > s.Radius {
+		return mgl.Vec2{}
+	}
+	return direction(target, ship.Pos)
+}
+
+func (s *Arrive) Steer(dt float32, ship *Ship, world *World) mgl.Vec2 {
+	move := s.Target.Position(world, ship.Pos).Sub(ship.Pos)
+	dist := move.Len()
+	if dist < 1 {
+		return mgl.Vec2{}
+	}
+	speed := float32(1)
+	if s.Radius > 0 {
+		speed = Min(1, dist/s.Radius)
+	}
+	return move.Mul(speed / dist)
+}
+
+func (s *Strafe) Steer(dt float32, ship *Ship, world *World) mgl.Vec2 {
+	s.t += dt
+	if s.Period > 0 && s.t > 2*s.Period {
+		s.t -= 2 * s.Period
+	}
+
+	dir := direction(ship.Pos, s.Target.Position(world, ship.Pos))
+	side := mgl.Vec2{-dir.Y(), dir.X()}
+	if s.t > s.Period {
+		side = side.Mul(-1)
+	}
+	return side
+}
+
+func (s *Orbit) Steer(dt float32, ship *Ship, world *World) mgl.Vec2 {
+	toTarget := s.Target.Position(world, ship.Pos).Sub(ship.Pos)
+	dist := toTarget.Len()
+	if dist < 1 || s.Radius <= 0 {
+		return mgl.Vec2{}
+	}
+
+	dir := toTarget.Mul(1 / dist)
+	tangent := mgl.Vec2{-dir.Y(), dir.X()}
+	if s.Clockwise {
+		tangent = tangent.Mul(-1)
+	}
+	radial := dir.Mul(mgl.Clamp((dist-s.Radius)/s.Radius, -1, 1))
+	return tangent.Add(radial).Normalize()
+}
+
+func (b Blend) Steer(dt float32, ship *Ship, world *World) mgl.Vec2 {
+	var thrust mgl.Vec2
+	for _, w := range b {
+		thrust = thrust.Add(w.Steering.Steer(dt, ship, world).Mul(w.Weight))
+	}
+	if thrust.Len() > 1 {
+		thrust = thrust.Normalize()
+	}
+	return thrust
+}
+
+// Aim turns the ship and tells whether to fire.
+func (a *AimAtPlayer) Aim(dt float32, ship *Ship, world *World) bool {
+	player := world.NearestShip(ship.Pos, Human, Autopilot)
+	if player == nil {
+		return false
+	}
+
+	want := direction(ship.Pos, player.Pos)
+	if want == (mgl.Vec2{}) {
+		return true
+	}
+	angle := angleBetween(ship.Dir, want)
+	turn := a.TurnRate * dt
+	if mgl.Abs(angle) <= turn {
+		ship.Dir = want
+	} else {
+		if angle < 0 {
+			turn = -turn
+		}
+		ship.Dir = mgl.Rotate2D(turn).Mul2x1(ship.Dir).Normalize()
+	}
+
+	return mgl.Abs(angleBetween(ship.Dir, want)) <= a.FireAngle
+}
+
+func direction(from, to mgl.Vec2) mgl.Vec2 {
+	dir := to.Sub(from)
+	if dir.Len() < 1e-3 {
+		return mgl.Vec2{}
+	}
+	return dir.Normalize()
+}
+
+// angleBetween is a signed angle from a to b.
+func angleBetween(a, b mgl.Vec2) float32 {
+	return float32(math.Atan2(float64(cross(a, b)), float64(a.Dot(b))))
+}
+
+// wrapAngle brings an angle to -Pi..Pi.
+func wrapAngle(a float32) float32 {
+	return float32(math.Remainder(float64(a), 2*math.Pi))
+}
@@ -0,0 +1,540 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+)
+
+const svgCurveSegments = 8
+
+// SVGShape is a ship drawing imported from an SVG file. Every filled
+// <path> or <polygon> is a layer, fills are inherited from groups and are
+// black by default as in SVG. The one with id "color1", or the first
+// one, is the hull; it is scaled to -1..1 with the nose pointing up in the
+// drawing. The one with id "color2" is drawn with Color2, the rest are
+// drawn with their own fill colors.
+type SVGShape struct {
+	Size    mgl.Vec2 // hull size in drawing units
+	Outline []mgl.Vec2
+	Holes   [][]mgl.Vec2
+	Color1  mgl.Vec4
+	Color2  mgl.Vec4
+	Layers  []HullLayer
+}
+
+// svgFill is the fill an element passes on to its children.
+type svgFill struct {
+	fill    string // empty is black
+	opacity string
+}
+
+type svgElement struct {
+	id       string
+	fill     mgl.Vec4
+	subpaths [][]mgl.Vec2
+}
+
+func ImportSVG(path string) (*SVGShape, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	elements, err := readSVG(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if len(elements) == 0 {
+		return nil, fmt.Errorf("%s: no filled paths or polygons", path)
+	}
+
+	hull := 0
+	for i, e := range elements {
+		if e.id == "color1" {
+			hull = i
+		}
+	}
+
+	// the hull's bounding box becomes -1..1, y is flipped
+	min, max := bounds(elements[hull].subpaths)
+	size := max.Sub(min)
+	if size.X() < triangulateEps || size.Y() < triangulateEps {
+		return nil, fmt.Errorf("%s: hull has no area", path)
+	}
+	center := min.Add(max).Mul(0.5)
+	for _, e := range elements {
+		for _, subpath := range e.subpaths {
+			for i, p := range subpath {
+				p = p.Sub(center)
+				subpath[i] = mgl.Vec2{2 * p.X() / size.X(),
+					-2 * p.Y() / size.Y()}
+			}
+		}
+	}
+
+	shape := &SVGShape{Size: size, Color1: elements[hull].fill}
+	shape.Outline, shape.Holes = splitHoles(elements[hull].subpaths)
+	for i, e := range elements {
+		if i == hull {
+			continue
+		}
+		layer := HullLayer{Color: e.fill}
+		layer.Outline, layer.Holes = splitHoles(e.subpaths)
+		if e.id == "color2" {
+			shape.Color2 = e.fill
+			layer.Color = mgl.Vec4{}
+		}
+		shape.Layers = append(shape.Layers, layer)
+	}
+	return shape, nil
+}
+
+// readSVG collects filled shapes in drawing order with group transforms
+// applied.
+func readSVG(r io.Reader) ([]svgElement, error) {
+	decoder := xml.NewDecoder(r)
+	transforms := []mgl.Mat3{mgl.Ident3()}
+	fills := []svgFill{{}}
+	hidden := 0 // depth inside of defs and the like
+	var elements []svgElement
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return elements, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			attrs := make(map[string]string)
+			for _, attr := range t.Attr {
+				attrs[attr.Name.Local] = attr.Value
+			}
+			transform, err := parseTransform(attrs["transform"])
+			if err != nil {
+				return nil, err
+			}
+			transform = transforms[len(transforms)-1].Mul3(transform)
+			transforms = append(transforms, transform)
+			style := fills[len(fills)-1].inherit(attrs)
+			fills = append(fills, style)
+
+			switch t.Name.Local {
+			case "defs", "clipPath", "mask", "pattern", "symbol", "marker":
+				hidden++
+			}
+			if hidden > 0 {
+				continue
+			}
+
+			var subpaths [][]mgl.Vec2
+			var fill mgl.Vec4
+			var filled bool
+			switch t.Name.Local {
+			case "path":
+				subpaths, err = parsePath(attrs["d"])
+			case "polygon":
+				var points []mgl.Vec2
+				points, err = parsePoints(attrs["points"])
+				subpaths = [][]mgl.Vec2{points}
+			default:
+				continue
+			}
+			if err == nil {
+				fill, filled, err = style.color()
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s %q: %v", t.Name.Local, attrs["id"],
+					err)
+			}
+			if !filled {
+				continue
+			}
+
+			for _, subpath := range subpaths {
+				for i, p := range subpath {
+					subpath[i] = transform.Mul3x1(p.Vec3(1)).Vec2()
+				}
+			}
+			elements = append(elements, svgElement{
+				id:       attrs["id"],
+				fill:     fill,
+				subpaths: subpaths,
+			})
+
+		case xml.EndElement:
+			transforms = transforms[:len(transforms)-1]
+			fills = fills[:len(fills)-1]
+			switch t.Name.Local {
+			case "defs", "clipPath", "mask", "pattern", "symbol", "marker":
+				hidden--
+			}
+		}
+	}
+}
+
+// splitHoles takes the largest subpath as the outline and the rest as holes.
+func splitHoles(subpaths [][]mgl.Vec2) ([]mgl.Vec2, [][]mgl.Vec2) {
+	outline := 0
+	for i, subpath := range subpaths {
+		if mgl.Abs(polygonArea(subpath)) >
+			mgl.Abs(polygonArea(subpaths[outline])) {
+			outline = i
+		}
+	}
+
+	var holes [][]mgl.Vec2
+	for i, subpath := range subpaths {
+		if i != outline {
+			holes = append(holes, subpath)
+		}
+	}
+	return subpaths[outline], holes
+}
+
+func bounds(subpaths [][]mgl.Vec2) (min, max mgl.Vec2) {
+	min = subpaths[0][0]
+	max = min
+	for _, subpath := range subpaths {
+		min = MinVec2(min, subpath...)
+		max = MaxVec2(max, subpath...)
+	}
+	return min, max
+}
+
+// inherit overrides the parent fill with fill attributes and styles.
+func (p svgFill) inherit(attrs map[string]string) svgFill {
+	set := func(value *string, s string) {
+		if s = strings.TrimSpace(s); s != "" && s != "inherit" {
+			*value = s
+		}
+	}
+	set(&p.fill, attrs["fill"])
+	set(&p.opacity, attrs["fill-opacity"])
+	for _, decl := range strings.Split(attrs["style"], ";") {
+		kv := strings.SplitN(decl, ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch strings.TrimSpace(kv[0]) {
+		case "fill":
+			set(&p.fill, kv[1])
+		case "fill-opacity":
+			set(&p.opacity, kv[1])
+		}
+	}
+	return p
+}
+
+func (p svgFill) color() (mgl.Vec4, bool, error) {
+	fill := p.fill
+	switch fill {
+	case "none":
+		return mgl.Vec4{}, false, nil
+	case "", "black":
+		fill = "#000000"
+	}
+	if len(fill) == 4 && fill[0] == '#' { // #rgb
+		fill = string([]byte{'#', fill[1], fill[1], fill[2], fill[2],
+			fill[3], fill[3]})
+	}
+	if len(fill) != 7 || fill[0] != '#' {
+		return mgl.Vec4{}, false, fmt.Errorf("unsupported fill %q", fill)
+	}
+	color, err := ParseColor(fill)
+	if err != nil {
+		return mgl.Vec4{}, false, err
+	}
+
+	if p.opacity != "" {
+		alpha, err := strconv.ParseFloat(p.opacity, 32)
+		if err != nil {
+			return mgl.Vec4{}, false,
+				fmt.Errorf("bad fill-opacity %q", p.opacity)
+		}
+		color[3] = float32(alpha)
+	}
+	return color, true, nil
+}
+
+func parseTransform(s string) (mgl.Mat3, error) {
+	result := mgl.Ident3()
+	s = strings.TrimSpace(s)
+
+	for s != "" {
+		open := strings.IndexByte(s, '(')
+		close := strings.IndexByte(s, ')')
+		if open < 0 || close < open {
+			return result, fmt.Errorf("bad transform %q", s)
+		}
+		name := strings.TrimSpace(s[:open])
+		args, err := parseNumbers(s[open+1 : close])
+		if err != nil {
+			return result, err
+		}
+		s = strings.TrimLeft(s[close+1:], " \t\r\n,")
+
+		var m mgl.Mat3
+		switch {
+		case name == "matrix" && len(args) == 6:
+			m = mgl.Mat3{args[0], args[1], 0, args[2], args[3], 0,
+				args[4], args[5], 1}
+		case name == "translate" && len(args) == 1:
+			m = mgl.Translate2D(args[0], 0)
+		case name == "translate" && len(args) == 2:
+			m = mgl.Translate2D(args[0], args[1])
+		case name == "scale" && len(args) == 1:
+			m = mgl.Scale2D(args[0], args[0])
+		case name == "scale" && len(args) == 2:
+			m = mgl.Scale2D(args[0], args[1])
+		case name == "rotate" && (len(args) == 1 || len(args) == 3):
+			m = mgl.HomogRotate2D(mgl.DegToRad(args[0]))
+			if len(args) == 3 {
+				m = mgl.Translate2D(args[1], args[2]).Mul3(m).Mul3(
+					mgl.Translate2D(-args[1], -args[2]))
+			}
+		default:
+			return result, fmt.Errorf("unsupported transform %q", name)
+		}
+		result = result.Mul3(m)
+	}
+
+	return result, nil
+}
+
+func parsePoints(s string) ([]mgl.Vec2, error) {
+	numbers, err := parseNumbers(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(numbers) < 6 || len(numbers)%2 != 0 {
+		return nil, errors.New("bad points")
+	}
+
+	points := make([]mgl.Vec2, len(numbers)/2)
+	for i := range points {
+		points[i] = mgl.Vec2{numbers[i*2], numbers[i*2+1]}
+	}
+	return points, nil
+}
+
+// parsePath reads path data into closed subpaths, curves are flattened.
+func parsePath(d string) ([][]mgl.Vec2, error) {
+	var subpaths [][]mgl.Vec2
+	var current []mgl.Vec2
+	var pos, start, ctrl mgl.Vec2
+	var cmd byte
+
+	closePath := func() {
+		if len(current) > 1 && current[0].ApproxEqual(current[len(current)-1]) {
+			current = current[:len(current)-1]
+		}
+		if len(current) >= 3 {
+			subpaths = append(subpaths, current)
+		}
+		current = nil
+	}
+
+	scanner := pathScanner{data: d}
+	for {
+		if c, ok := scanner.command(); ok {
+			cmd = c
+		} else if scanner.done() {
+			break
+		} else if cmd == 0 {
+			return nil, fmt.Errorf("bad path data %q", d)
+		}
+
+		relative := cmd >= 'a'
+		point := func() (mgl.Vec2, error) {
+			x, err := scanner.number()
+			if err != nil {
+				return mgl.Vec2{}, err
+			}
+			y, err := scanner.number()
+			if err != nil {
+				return mgl.Vec2{}, err
+			}
+			if relative {
+				return pos.Add(mgl.Vec2{x, y}), nil
+			}
+			return mgl.Vec2{x, y}, nil
+		}
+
+		var err error
+		var p, c1, c2 mgl.Vec2 // end point and control points
+		switch cmd {
+		case 'M', 'm':
+			closePath()
+			if p, err = point(); err == nil {
+				pos, start, ctrl = p, p, p
+				current = append(current, p)
+				cmd -= 1 // following pairs are lineto
+			}
+		case 'L', 'l':
+			if p, err = point(); err == nil {
+				pos, ctrl = p, p
+				current = append(current, p)
+			}
+		case 'H', 'h', 'V', 'v':
+			var v float32
+			if v, err = scanner.number(); err == nil {
+				p = pos
+				axis := 0
+				if cmd == 'V' || cmd == 'v' {
+					axis = 1
+				}
+				if relative {
+					p[axis] += v
+				} else {
+					p[axis] = v
+				}
+				pos, ctrl = p, p
+				current = append(current, p)
+			}
+		case 'C', 'c', 'S', 's':
+			c1 = pos.Mul(2).Sub(ctrl) // reflection of the last control point
+			if cmd == 'C' || cmd == 'c' {
+				c1, err = point()
+			}
+			if err == nil {
+				c2, err = point()
+			}
+			if err == nil {
+				p, err = point()
+			}
+			if err == nil {
+				current = appendCubic(current, pos, c1, c2, p)
+				pos, ctrl = p, c2
+			}
+		case 'Q', 'q', 'T', 't':
+			c1 = pos.Mul(2).Sub(ctrl)
+			if cmd == 'Q' || cmd == 'q' {
+				c1, err = point()
+			}
+			if err == nil {
+				p, err = point()
+			}
+			if err == nil {
+				current = appendCubic(current, pos,
+					pos.Add(c1.Sub(pos).Mul(2.0/3)),
+					p.Add(c1.Sub(p).Mul(2.0/3)), p)
+				pos, ctrl = p, c1
+			}
+		case 'Z', 'z':
+			closePath()
+			pos, ctrl = start, start
+			cmd = 0
+		default:
+			return nil, fmt.Errorf("unsupported path command %q", cmd)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	closePath()
+	if len(subpaths) == 0 {
+		return nil, errors.New("path has no area")
+	}
+	return subpaths, nil
+}
+
+func appendCubic(points []mgl.Vec2, p0, p1, p2, p3 mgl.Vec2) []mgl.Vec2 {
+	for i := 1; i <= svgCurveSegments; i++ {
+		t := float32(i) / svgCurveSegments
+		points = append(points, mgl.CubicBezierCurve2D(t, p0, p1, p2, p3))
+	}
+	return points
+}
+
+func parseNumbers(s string) ([]float32, error) {
+	var numbers []float32
+	scanner := pathScanner{data: s}
+	for !scanner.done() {
+		n, err := scanner.number()
+		if err != nil {
+			return nil, err
+		}
+		numbers = append(numbers, n)
+	}
+	return numbers, nil
+}
+
+type pathScanner struct {
+	data string
+	pos  int
+}
+
+func (s *pathScanner) skipSpace() {
+	for s.pos < len(s.data) &&
+		strings.IndexByte(" \t\r\n,", s.data[s.pos]) >= 0 {
+
+		s.pos++
+	}
+}
+
+func (s *pathScanner) done() bool {
+	s.skipSpace()
+	return s.pos >= len(s.data)
+}
+
+func (s *pathScanner) command() (byte, bool) {
+	s.skipSpace()
+	if s.pos < len(s.data) {
+		c := s.data[s.pos]
+		if c != 'e' && c != 'E' &&
+			(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z') {
+			s.pos++
+			return c, true
+		}
+	}
+	return 0, false
+}
+
+// number reads numbers written without separators too, like "1.5.5-2".
+func (s *pathScanner) number() (float32, error) {
+	s.skipSpace()
+	start := s.pos
+	end := s.pos
+	if end < len(s.data) && (s.data[end] == '-' || s.data[end] == '+') {
+		end++
+	}
+	dot, exp := false, false
+scan:
+	for ; end < len(s.data); end++ {
+		c := s.data[end]
+		switch {
+		case c >= '0' && c <= '9':
+		case c == '.' && !dot && !exp:
+			dot = true
+		case (c == 'e' || c == 'E') && !exp && end > start:
+			exp = true
+			if end+1 < len(s.data) &&
+				(s.data[end+1] == '-' || s.data[end+1] == '+') {
+				end++
+			}
+		default:
+			break scan
+		}
+	}
+
+	n, err := strconv.ParseFloat(s.data[start:end], 32)
+	if err != nil || math.IsInf(n, 0) {
+		return 0, fmt.Errorf("bad number at %q", s.data[start:])
+	}
+	s.pos = end
+	return float32(n), nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+)
+
+func TestParsePath(t *testing.T) {
+	tests := []struct {
+		d    string
+		want [][]mgl.Vec2
+	}{
+		{"M0 0 L10 0 L10 10 Z", [][]mgl.Vec2{{{0, 0}, {10, 0}, {10, 10}}}},
+		{"m1 1 9 0 0 9z", [][]mgl.Vec2{{{1, 1}, {10, 1}, {10, 10}}}},
+		{"M0,0H10V10H0z", [][]mgl.Vec2{{{0, 0}, {10, 0}, {10, 10}, {0, 10}}}},
+		{"M0-1L.5.5-2 3z", [][]mgl.Vec2{{{0, -1}, {0.5, 0.5}, {-2, 3}}}},
+		{"M0 0L4 0L4 4L0 0", [][]mgl.Vec2{{{0, 0}, {4, 0}, {4, 4}}}},
+		{"M0 0h4v4h-4z M1 1h2v2h-2z", [][]mgl.Vec2{
+			{{0, 0}, {4, 0}, {4, 4}, {0, 4}},
+			{{1, 1}, {3, 1}, {3, 3}, {1, 3}},
+		}},
+		{"M0 0L1e1 0L1E1 1e1z", [][]mgl.Vec2{{{0, 0}, {10, 0}, {10, 10}}}},
+	}
+
+	for _, test := range tests {
+		got, err := parsePath(test.d)
+		if err != nil {
+			t.Errorf("%q: %v", test.d, err)
+			continue
+		}
+		if !equalSubpaths(got, test.want) {
+			t.Errorf("%q: got %v, want %v", test.d, got, test.want)
+		}
+	}
+}
+
+func TestParsePathCurves(t *testing.T) {
+	for _, d := range []string{
+		"M0 0C0 10 10 10 10 0Z",
+		"M0 0c0 10 10 10 10 0z",
+		"M0 0Q5 15 10 0Z",
+	} {
+		subpaths, err := parsePath(d)
+		if err != nil {
+			t.Fatalf("%q: %v", d, err)
+		}
+		if len(subpaths) != 1 || len(subpaths[0]) != svgCurveSegments+1 {
+			t.Fatalf("%q: got %v", d, subpaths)
+		}
+		points := subpaths[0]
+		if mid := points[svgCurveSegments/2]; mid.Sub(
+			mgl.Vec2{5, 7.5}).Len() > 1e-4 {
+
+			t.Errorf("%q: curve goes through %v, want [5 7.5]", d, mid)
+		}
+		if end := points[svgCurveSegments]; !end.ApproxEqual(mgl.Vec2{10, 0}) {
+			t.Errorf("%q: curve ends at %v", d, end)
+		}
+	}
+}
+
+func TestParsePathErrors(t *testing.T) {
+	for _, d := range []string{
+		"",
+		"0 0 1 1",
+		"M0 0L1 1",
+		"M0 0L1",
+		"M0 0L1 1A1 1 0 0 0 2 2z",
+		"M0 0L1 x",
+	} {
+		if subpaths, err := parsePath(d); err == nil {
+			t.Errorf("%q: no error, got %v", d, subpaths)
+		}
+	}
+}
+
+func TestReadSVGFills(t *testing.T) {
+	const svg = `<svg xmlns="http://www.w3.org/2000/svg">
+	<defs><path id="hidden" d="M0 0h1v1z"/></defs>
+	<g fill="#ff0000" fill-opacity="0.5">
+		<path id="red" d="M0 0h1v1z"/>
+		<g style="fill: #00f">
+			<polygon id="blue" points="0 0 1 0 1 1"/>
+		</g>
+		<path id="none" fill="none" d="M0 0h1v1z"/>
+	</g>
+	<path id="black" d="M0 0h1v1z"/>
+</svg>`
+
+	elements, err := readSVG(strings.NewReader(svg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		id   string
+		fill mgl.Vec4
+	}{
+		{"red", mgl.Vec4{1, 0, 0, 0.5}},
+		{"blue", mgl.Vec4{0, 0, 1, 0.5}},
+		{"black", mgl.Vec4{0, 0, 0, 1}},
+	}
+	if len(elements) != len(want) {
+		t.Fatalf("got %d elements, want %d", len(elements), len(want))
+	}
+	for i, e := range elements {
+		if e.id != want[i].id || !e.fill.ApproxEqual(want[i].fill) {
+			t.Errorf("got %s %v, want %s %v", e.id, e.fill, want[i].id,
+				want[i].fill)
+		}
+	}
+}
+
+func equalSubpaths(a, b [][]mgl.Vec2) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if len(a[i]) != len(b[i]) {
+			return false
+		}
+		for j := range a[i] {
+			if !a[i][j].ApproxEqual(b[i][j]) {
+				return false
+			}
+		}
+	}
+	return true
+}
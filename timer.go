@@ -1,3 +1,6 @@
+//go:build !headless
+// +build !headless
+
 package main
 
 import (
@@ -6,12 +9,15 @@ import (
 	"github.com/go-gl/glfw/v3.1/glfw"
 )
 
+const maxSteps = StepRate / 4 // don't try to catch up more than 0.25s
+
 type Timer struct {
 	DT         float32
 	TicksCount int
 
-	updated    float64
-	ticksTotal float32
+	updated     float64
+	ticksTotal  float32
+	accumulator float32
 }
 
 func NewTimer() Timer {
@@ -27,10 +33,20 @@ func (t *Timer) Tick() float32 {
 
 	t.ticksTotal += t.DT
 	t.TicksCount += 1
+	t.accumulator += t.DT
 
 	return t.DT
 }
 
+func (t *Timer) Steps() int {
+	steps := int(t.accumulator / StepDT)
+	t.accumulator -= float32(steps) * StepDT
+	if steps > maxSteps {
+		steps = maxSteps
+	}
+	return steps
+}
+
 func (t *Timer) Stat() string {
 	avg := t.ticksTotal / float32(t.TicksCount)
 	return fmt.Sprintf("%.2f %.f", avg*1000, 1/avg)
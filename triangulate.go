@@ -0,0 +1,221 @@
+package main
+
+import (
+	"errors"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+)
+
+const triangulateEps = 1e-6
+
+// Triangulate splits a simple polygon with optional holes into a triangle
+// list by ear clipping. Holes are joined to the outline with bridges first,
+// so ear clipping sees one polygon. Winding of the input doesn't matter.
+func Triangulate(outline []mgl.Vec2, holes [][]mgl.Vec2) ([]mgl.Vec2, error) {
+	if len(outline) < 3 || mgl.Abs(polygonArea(outline)) < triangulateEps {
+		return nil, errors.New("outline is degenerate")
+	}
+	for _, hole := range holes {
+		if len(hole) < 3 || mgl.Abs(polygonArea(hole)) < triangulateEps {
+			return nil, errors.New("hole is degenerate")
+		}
+	}
+
+	poly := withWinding(outline, true)
+	poly, err := bridgeHoles(poly, holes)
+	if err != nil {
+		return nil, err
+	}
+	return clipEars(poly)
+}
+
+func polygonArea(poly []mgl.Vec2) float32 {
+	var area float32
+	for i := range poly {
+		p1, p2 := poly[i], poly[(i+1)%len(poly)]
+		area += p1.X()*p2.Y() - p2.X()*p1.Y()
+	}
+	return area / 2
+}
+
+func withWinding(poly []mgl.Vec2, ccw bool) []mgl.Vec2 {
+	result := append([]mgl.Vec2(nil), poly...)
+	if (polygonArea(result) > 0) != ccw {
+		for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+			result[i], result[j] = result[j], result[i]
+		}
+	}
+	return result
+}
+
+// bridgeHoles cuts every hole into poly along a segment from the hole's
+// rightmost point to the nearest poly vertex it can see.
+func bridgeHoles(poly []mgl.Vec2, holes [][]mgl.Vec2) ([]mgl.Vec2, error) {
+	pending := make([][]mgl.Vec2, len(holes))
+	for i, hole := range holes {
+		pending[i] = withWinding(hole, false)
+	}
+
+	for len(pending) > 0 {
+		hole := pending[0]
+		pending = pending[1:]
+
+		m := rightmost(hole)
+		p := visibleVertex(hole[m], poly, append(pending, hole))
+		if p < 0 {
+			return nil, errors.New("hole is outside of the outline")
+		}
+
+		merged := make([]mgl.Vec2, 0, len(poly)+len(hole)+2)
+		merged = append(merged, poly[:p+1]...)
+		for i := 0; i <= len(hole); i++ {
+			merged = append(merged, hole[(m+i)%len(hole)])
+		}
+		merged = append(merged, poly[p:]...)
+		poly = merged
+	}
+
+	return poly, nil
+}
+
+func rightmost(poly []mgl.Vec2) int {
+	best := 0
+	for i, p := range poly {
+		if p.X() > poly[best].X() {
+			best = i
+		}
+	}
+	return best
+}
+
+// visibleVertex finds the nearest vertex of poly that can be connected
+// to point without leaving poly or crossing any hole.
+func visibleVertex(point mgl.Vec2, poly []mgl.Vec2,
+	holes [][]mgl.Vec2) int {
+
+	best := -1
+	var bestDist float32
+	for i, p := range poly {
+		dist := p.Sub(point).Len()
+		if best >= 0 && dist >= bestDist {
+			continue
+		}
+		if !insideCorner(poly, i, point) || crossesAny(point, p, poly) {
+			continue
+		}
+		blocked := false
+		for _, hole := range holes {
+			if crossesAny(point, p, hole) {
+				blocked = true
+				break
+			}
+		}
+		if !blocked {
+			best, bestDist = i, dist
+		}
+	}
+	return best
+}
+
+func crossesAny(a, b mgl.Vec2, poly []mgl.Vec2) bool {
+	for i := range poly {
+		c, d := poly[i], poly[(i+1)%len(poly)]
+		if c.ApproxEqual(a) || c.ApproxEqual(b) ||
+			d.ApproxEqual(a) || d.ApproxEqual(b) {
+			continue
+		}
+		if ok, _ := SegmentIntersection(a, b, c, d); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// insideCorner tells if the direction from vertex i of a counter-clockwise
+// polygon to point goes inside of the polygon.
+func insideCorner(poly []mgl.Vec2, i int, point mgl.Vec2) bool {
+	prev := poly[(i+len(poly)-1)%len(poly)].Sub(poly[i])
+	next := poly[(i+1)%len(poly)].Sub(poly[i])
+	dir := point.Sub(poly[i])
+
+	if cross(next, prev) >= 0 { // convex corner
+		return cross(next, dir) > 0 && cross(dir, prev) > 0
+	}
+	return !(cross(prev, dir) >= 0 && cross(dir, next) >= 0)
+}
+
+// clipEars triangulates a counter-clockwise polygon, which may touch itself
+// at bridge vertices.
+func clipEars(poly []mgl.Vec2) ([]mgl.Vec2, error) {
+	triangles := make([]mgl.Vec2, 0, (len(poly)-2)*3)
+	poly = append([]mgl.Vec2(nil), poly...)
+
+	for len(poly) > 3 {
+		ear := -1
+		for i := range poly {
+			if isEar(poly, i) {
+				ear = i
+				break
+			}
+		}
+		if ear < 0 {
+			ear = flatVertex(poly)
+			if ear < 0 {
+				return nil, errors.New("outline intersects itself")
+			}
+			poly = append(poly[:ear], poly[ear+1:]...)
+			continue
+		}
+
+		prev := poly[(ear+len(poly)-1)%len(poly)]
+		next := poly[(ear+1)%len(poly)]
+		triangles = append(triangles, prev, poly[ear], next)
+		poly = append(poly[:ear], poly[ear+1:]...)
+	}
+
+	if polygonArea(poly) > triangulateEps {
+		triangles = append(triangles, poly...)
+	}
+	return triangles, nil
+}
+
+func flatVertex(poly []mgl.Vec2) int {
+	for i := range poly {
+		a := poly[(i+len(poly)-1)%len(poly)]
+		c := poly[(i+1)%len(poly)]
+		if mgl.Abs(cross(poly[i].Sub(a), c.Sub(poly[i]))) <= triangulateEps {
+			return i
+		}
+	}
+	return -1
+}
+
+func isEar(poly []mgl.Vec2, i int) bool {
+	a := poly[(i+len(poly)-1)%len(poly)]
+	b := poly[i]
+	c := poly[(i+1)%len(poly)]
+	if cross(b.Sub(a), c.Sub(b)) <= triangulateEps {
+		return false
+	}
+
+	for _, p := range poly {
+		if p.ApproxEqual(a) || p.ApproxEqual(b) || p.ApproxEqual(c) {
+			continue
+		}
+		if pointInTriangle(p, a, b, c) {
+			return false
+		}
+	}
+	return true
+}
+
+func pointInTriangle(p, a, b, c mgl.Vec2) bool {
+	d1 := cross(b.Sub(a), p.Sub(a))
+	d2 := cross(c.Sub(b), p.Sub(b))
+	d3 := cross(a.Sub(c), p.Sub(c))
+	return d1 >= 0 && d2 >= 0 && d3 >= 0
+}
+
+func cross(a, b mgl.Vec2) float32 {
+	return a.X()*b.Y() - a.Y()*b.X()
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"testing"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+)
+
+func TestTriangulate(t *testing.T) {
+	square := func(x, y, size float32) []mgl.Vec2 {
+		return []mgl.Vec2{{x, y}, {x + size, y}, {x + size, y + size},
+			{x, y + size}}
+	}
+	reversed := func(poly []mgl.Vec2) []mgl.Vec2 {
+		r := make([]mgl.Vec2, len(poly))
+		for i, p := range poly {
+			r[len(poly)-1-i] = p
+		}
+		return r
+	}
+	lShape := []mgl.Vec2{{0, 0}, {4, 0}, {4, 1}, {1, 1}, {1, 4}, {0, 4}}
+	comb := []mgl.Vec2{{0, 0}, {7, 0}, {7, 3}, {6, 3}, {6, 1}, {5, 1},
+		{5, 3}, {4, 3}, {4, 1}, {3, 1}, {3, 3}, {2, 3}, {2, 1}, {1, 1},
+		{1, 3}, {0, 3}}
+	uShape := []mgl.Vec2{{0, 0}, {9, 0}, {9, 9}, {6, 9}, {6, 3}, {3, 3},
+		{3, 9}, {0, 9}}
+
+	tests := []struct {
+		name    string
+		outline []mgl.Vec2
+		holes   [][]mgl.Vec2
+	}{
+		{"square", square(0, 0, 2), nil},
+		{"concave", lShape, nil},
+		{"clockwise", reversed(lShape), nil},
+		{"comb", comb, nil},
+		{"fighter", FighterModel.Outline, nil},
+		{"hole", square(0, 0, 10), [][]mgl.Vec2{square(4, 4, 2)}},
+		{"clockwise hole", square(0, 0, 10),
+			[][]mgl.Vec2{reversed(square(4, 4, 2))}},
+		{"holes", square(0, 0, 10), [][]mgl.Vec2{square(1, 1, 2),
+			square(6, 1, 3), square(3, 6, 2)}},
+		{"concave with holes", uShape, [][]mgl.Vec2{square(1, 1, 1),
+			square(1, 5, 1), square(7, 4, 1)}},
+	}
+
+	for _, test := range tests {
+		triangles, err := Triangulate(test.outline, test.holes)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+
+		vertices := len(test.outline)
+		area := mgl.Abs(polygonArea(test.outline))
+		for _, hole := range test.holes {
+			vertices += len(hole) + 2 // and two bridge ends
+			area -= mgl.Abs(polygonArea(hole))
+		}
+		if len(triangles)%3 != 0 || len(triangles)/3 != vertices-2 {
+			t.Errorf("%s: %d vertices make %d triangles", test.name,
+				vertices-2*len(test.holes), len(triangles)/3)
+			continue
+		}
+
+		var sum float32
+		for i := 0; i < len(triangles); i += 3 {
+			triangle := triangles[i : i+3]
+			a := polygonArea(triangle)
+			if a <= 0 {
+				t.Errorf("%s: triangle %v is flat or clockwise", test.name,
+					triangle)
+			}
+			sum += a
+
+			center := triangle[0].Add(triangle[1]).Add(triangle[2]).Mul(1.0 / 3)
+			inside := insidePolygon(center, test.outline)
+			for _, hole := range test.holes {
+				inside = inside && !insidePolygon(center, hole)
+			}
+			if !inside {
+				t.Errorf("%s: triangle %v is outside", test.name, triangle)
+			}
+		}
+		if mgl.Abs(sum-area) > 1e-4*area {
+			t.Errorf("%s: triangles cover %v, want %v", test.name, sum, area)
+		}
+	}
+}
+
+func TestTriangulateDegenerate(t *testing.T) {
+	square := []mgl.Vec2{{0, 0}, {1, 0}, {1, 1}, {0, 1}}
+	line := []mgl.Vec2{{0, 0}, {1, 1}, {2, 2}}
+
+	tests := []struct {
+		outline []mgl.Vec2
+		holes   [][]mgl.Vec2
+	}{
+		{nil, nil},
+		{square[:2], nil},
+		{line, nil},
+		{square, [][]mgl.Vec2{line}},
+		{square, [][]mgl.Vec2{square[:2]}},
+	}
+	for _, test := range tests {
+		if _, err := Triangulate(test.outline, test.holes); err == nil {
+			t.Errorf("no error for %v with holes %v", test.outline,
+				test.holes)
+		}
+	}
+}
+
+// insidePolygon tells if point is inside of poly by the even-odd rule.
+func insidePolygon(point mgl.Vec2, poly []mgl.Vec2) bool {
+	inside := false
+	for i := range poly {
+		a, b := poly[i], poly[(i+1)%len(poly)]
+		if (a.Y() > point.Y()) != (b.Y() > point.Y()) {
+			x := a.X() + (point.Y()-a.Y())/(b.Y()-a.Y())*(b.X()-a.X())
+			if point.X() < x {
+				inside = !inside
+			}
+		}
+	}
+	return inside
+}
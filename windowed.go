@@ -0,0 +1,140 @@
+//go:build !headless
+// +build !headless
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/go-gl/gl/v3.2-core/gl"
+	"github.com/go-gl/glfw/v3.1/glfw"
+)
+
+const windowSupport = true
+
+func init() {
+	runtime.LockOSThread() // GLFW event handling must run on the main OS thread
+}
+
+func RunWindowed(opts *Options) {
+	player, recorder := opts.Player, opts.Recorder
+
+	PanicOnError(InitGLFW())
+	defer glfw.Terminate()
+
+	window, screenSize := NewWindow(width, height, title, opts.Fullscreen)
+	PanicOnError(gl.Init())
+
+	InitSound()
+	defer TerminateSound()
+	LoadSoundAssets("*.wav")
+
+	var controls Controls
+	input := NewInput(window, opts.Fullscreen)
+	renderer := NewRenderer(width, height, screenSize)
+	world := NewWorld(width, height, ALAudio{}, opts.Seed)
+	game := NewGame(world, &controls)
+	timer := NewTimer()
+	rewind := NewRewind(rewindSeconds)
+	step := 0
+
+	defer SaveOnPanic(game)
+	SetupGame(game, opts)
+
+	var models *ModelWatcher
+	switch {
+	case opts.Models == "":
+	case player != nil || recorder != nil:
+		fmt.Println("ship models are not reloaded in recordings and replays")
+	default:
+		models = NewModelWatcher(opts.Models)
+	}
+
+	resume := func() {
+		if rewind.InPast() {
+			step = rewind.Step()
+			if player != nil {
+				player.Seek(step)
+			}
+			if recorder != nil {
+				recorder.Truncate(step)
+			}
+		}
+		rewind.Paused = false
+	}
+
+	for !window.ShouldClose() {
+		steps := timer.Steps()
+		if rewind.Paused {
+			steps = 0
+			if input.StepForward && !rewind.Forward(game) {
+				steps = 1
+			}
+		}
+
+		for ; steps > 0; steps-- {
+			controls = StepControls(input.Controls, player, recorder)
+			game.Update(StepDT)
+			step += 1
+			if input.Debug {
+				rewind.Push(step, game.Snapshot())
+			}
+		}
+
+		renderer.Clear()
+		world.Draw(renderer)
+		renderer.Render()
+
+		window.SwapBuffers()
+
+		timer.Tick()
+		if timer.TicksCount == 60 {
+			window.SetTitle(timer.Stat())
+			timer.ResetCounter()
+			if models != nil {
+				reloaded, err := models.Reload()
+				if err != nil {
+					fmt.Println(err)
+				} else if reloaded {
+					world.SyncModels()
+					fmt.Println("ship models reloaded from", opts.Models)
+				}
+			}
+		}
+
+		input.Process()
+		switch {
+		case input.DebugToggled && input.Debug:
+			glfw.SwapInterval(0)
+		case input.DebugToggled:
+			glfw.SwapInterval(1)
+			resume()
+			rewind.Reset()
+		case input.Debug && input.PauseToggled && rewind.Paused:
+			resume()
+		case input.Debug && input.PauseToggled:
+			rewind.Paused = true
+		case input.Debug && input.StepBack:
+			rewind.Paused = true
+			rewind.Back(game)
+		case input.FullscreenToggled:
+			//renderer.Cleanup()
+			window.Destroy()
+			window, screenSize = NewWindow(width, height, title,
+				input.Fullscreen)
+			input.SetWindow(window)
+			renderer = NewRenderer(width, height, screenSize)
+		case input.QuickSave:
+			PanicOnError(game.SaveSnapshot(quickSavePath))
+		case input.QuickLoad && (player != nil || recorder != nil):
+			fmt.Println("quick load is off in recordings and replays")
+		case input.QuickLoad:
+			if err := game.LoadSnapshot(quickSavePath); err != nil {
+				fmt.Println(err)
+			} else {
+				rewind.Reset() // the frames are from another timeline
+			}
+		}
+	}
+}
@@ -1,14 +1,35 @@
 package main
 
-import mgl "github.com/go-gl/mathgl/mgl32"
+import (
+	"math"
+	"math/rand"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+)
+
+const (
+	fxSeedSalt   = 0x2545f4914f6cdd1d
+	gridCellSize = 64
+)
 
 type World struct {
 	Size      mgl.Vec2
 	TimeSpeed float32
+	Audio     Audio
+	Seed      int64
+	Rand      *rand.Rand // gameplay: spawns, enemy decisions
+	FxRand    *rand.Rand // cosmetics: particles, stars
+
+	randSrc   *RandSource
+	fxRandSrc *RandSource
 
 	ships    []*Ship
 	missiles []*Missile
 	objects  []WorldObject
+	grid     *ShipGrid
+
+	freeMissiles  []*Missile
+	freeParticles []*Particle
 }
 
 type Race int
@@ -21,34 +42,57 @@ const (
 
 type WorldObject interface {
 	Update(dt float32)
-	Draw(renderer *Renderer)
+	Draw(renderer Renderer)
 	IsDead() bool
 }
 
-func NewWorld(width, height float32) *World {
+func NewWorld(width, height float32, audio Audio, seed int64) *World {
 	w := World{
 		Size:      mgl.Vec2{width, height},
 		TimeSpeed: 1,
+		Audio:     audio,
+		Seed:      seed,
 	}
+	w.grid = NewShipGrid(w.Size, gridCellSize)
+	w.Rand, w.randSrc = NewRand(seed)
+	w.FxRand, w.fxRandSrc = NewRand(seed ^ fxSeedSalt)
 	return &w
 }
 
 func (w *World) Update(dt float32) {
+	for _, s := range w.ships {
+		if s.Pilot != nil {
+			s.Pilot.Update(dt*w.TimeSpeed, s, w)
+		}
+	}
+
+	// new ships and ones moved by stages or turned by pilots are swept
+	// from where they are now
+	for _, s := range w.ships {
+		s.updatePose()
+	}
+	w.grid.Build(w.ships, dt*w.TimeSpeed)
+	for _, s := range w.ships {
+		s.Collide(dt*w.TimeSpeed, w)
+	}
 	livingShips := w.ships[:0]
 	for _, s := range w.ships {
-		s.Update(dt*w.TimeSpeed, w, w.ships)
+		s.Update(dt*w.TimeSpeed, w)
 		if !s.IsDead {
 			livingShips = append(livingShips, s)
 		}
 	}
 	w.ships = livingShips
 
+	w.grid.Build(w.ships, 0)
 	livingMissiles := w.missiles[:0]
 	for _, m := range w.missiles {
-		m.Update(dt*w.TimeSpeed, w, w.ships)
+		m.Update(dt*w.TimeSpeed, w)
 		w.killStrayedMissile(m)
 		if !m.IsDead {
 			livingMissiles = append(livingMissiles, m)
+		} else {
+			w.freeMissiles = append(w.freeMissiles, m)
 		}
 	}
 	w.missiles = livingMissiles
@@ -58,12 +102,14 @@ func (w *World) Update(dt float32) {
 		o.Update(dt * w.TimeSpeed)
 		if !o.IsDead() {
 			livingObjects = append(livingObjects, o)
+		} else if p, ok := o.(*Particle); ok {
+			w.freeParticles = append(w.freeParticles, p)
 		}
 	}
 	w.objects = livingObjects
 }
 
-func (w *World) Draw(renderer *Renderer) {
+func (w *World) Draw(renderer Renderer) {
 	for _, o := range w.objects {
 		o.Draw(renderer)
 	}
@@ -77,6 +123,32 @@ func (w *World) Draw(renderer *Renderer) {
 	}
 }
 
+func (w *World) NewMissile(race Race, pos, velocity, size mgl.Vec2,
+	color mgl.Vec4) *Missile {
+
+	var m *Missile
+	if n := len(w.freeMissiles); n > 0 {
+		m, w.freeMissiles = w.freeMissiles[n-1], w.freeMissiles[:n-1]
+	} else {
+		m = new(Missile)
+	}
+	m.init(race, pos, velocity, size, color)
+	return m
+}
+
+func (w *World) NewParticle(pos mgl.Vec2, startSize, endSize,
+	lifetime float32, color mgl.Vec4) *Particle {
+
+	var p *Particle
+	if n := len(w.freeParticles); n > 0 {
+		p, w.freeParticles = w.freeParticles[n-1], w.freeParticles[:n-1]
+	} else {
+		p = new(Particle)
+	}
+	p.init(pos, startSize, endSize, lifetime, color)
+	return p
+}
+
 func (w *World) AddShips(ships ...*Ship) {
 	w.ships = append(w.ships, ships...)
 }
@@ -89,12 +161,70 @@ func (w *World) AddObjects(objects ...WorldObject) {
 	w.objects = append(w.objects, objects...)
 }
 
+func (w *World) PlaySound(label string, gain, pitch float32) {
+	w.Audio.Play(label, gain, pitch)
+}
+
+// ShipsNear returns ships that may intersect with aabb. The slice is
+// reused, it is valid until the next ShipsNear.
+func (w *World) ShipsNear(aabb mgl.Vec4) []*Ship {
+	return w.grid.Query(aabb)
+}
+
+// NearestShip finds the closest living ship of any of races.
+func (w *World) NearestShip(pos mgl.Vec2, races ...Race) *Ship {
+	return w.NearestShipInCone(pos, mgl.Vec2{}, math.Pi, races...)
+}
+
+// NearestShipInCone finds the closest living ship of any of races within
+// cone radians to each side of dir. A zero dir looks all around.
+func (w *World) NearestShipInCone(pos, dir mgl.Vec2, cone float32,
+	races ...Race) *Ship {
+
+	minCos := float32(math.Cos(float64(cone)))
+	var nearest *Ship
+	var nearestDist float32
+	for _, s := range w.ships {
+		if s.IsDead || !hasRace(races, s.Race) {
+			continue
+		}
+		if dir != (mgl.Vec2{}) && cone < math.Pi {
+			to := direction(pos, s.Pos)
+			if to != (mgl.Vec2{}) && to.Dot(dir) < minCos {
+				continue
+			}
+		}
+		dist := s.Pos.Sub(pos).LenSqr()
+		if nearest == nil || dist < nearestDist {
+			nearest, nearestDist = s, dist
+		}
+	}
+	return nearest
+}
+
+func hasRace(races []Race, race Race) bool {
+	for _, r := range races {
+		if r == race {
+			return true
+		}
+	}
+	return false
+}
+
 func (w *World) ShipCount() int {
 	return len(w.ships)
 }
 
+// SyncModels has to be called after ship models were changed in place.
+func (w *World) SyncModels() {
+	for _, s := range w.ships {
+		s.syncModel()
+	}
+}
+
 func (w *World) ResetMissilesAndShips() {
 	w.ships = nil
+	w.freeMissiles = append(w.freeMissiles, w.missiles...)
 	w.missiles = nil
 }
 
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+)
+
+// busyModel fires every kind of gun and can't be killed, so a world of
+// them reaches a steady state.
+func busyModel(t testing.TB) *ShipModel {
+	gun := func(pos mgl.Vec2) GunModel {
+		return GunModel{
+			Pos:   pos,
+			Rate:  4,
+			Speed: 400,
+			Size:  mgl.Vec2{9, 6},
+			Color: WhiteColor,
+		}
+	}
+
+	model := FighterModel
+	model.Hp = math.MaxInt32
+	model.Guns = []GunModel{gun(mgl.Vec2{0, 0.9}), gun(mgl.Vec2{-0.5, 0}),
+		gun(mgl.Vec2{0.5, 0}), gun(mgl.Vec2{0, 0}), gun(mgl.Vec2{0, -0.5})}
+	model.Guns[0].Pierce = 2
+	model.Guns[1].Emitter = &Emitter{Pattern: "spiral", Count: 4, Spin: 0.3,
+		Accel: 100, EndSpeed: 600}
+	model.Guns[1].Blast = 40
+	model.Guns[2].Emitter = &Emitter{Pattern: "cone", Aim: true, Count: 3,
+		Spread: 0.5}
+	model.Guns[2].Arc = math.Pi / 4
+	model.Guns[2].TurnRate = math.Pi
+	model.Guns[3].Homing = &Homing{Cone: math.Pi / 3, TurnRate: math.Pi,
+		Fuel: 1, Smoke: 30, SmokeColor: WhiteColor}
+	model.Guns[4].Blast = 30
+	model.Guns[4].FriendlyFire = true
+	model.Parts = []PartModel{{
+		Name:         "nose",
+		Outline:      []mgl.Vec2{{-0.3, 0.3}, {0.3, 0.3}, {0, 1}},
+		Hp:           math.MaxInt32,
+		Guns:         []int{0},
+		BlowupFactor: 1,
+	}}
+	if err := model.Triangulate(); err != nil {
+		t.Fatal(err)
+	}
+	return &model
+}
+
+// busyWorld has n ships of each side circling around and shooting at each
+// other, half of the enemies fly in a formation.
+func busyWorld(t testing.TB, n int) *World {
+	model := busyModel(t)
+	world := NewWorld(width, height, NullAudio{}, 1)
+
+	for i := 0; i < n; i++ {
+		s := NewShip(Human, model)
+		s.Pos = mgl.Vec2{200 + float32(i%10)*30, 100 + float32(i/10)*60}
+		s.Dir = mgl.Vec2{1, 0}
+		s.Pilot = &SteeringPilot{Fire: true, Move: &Orbit{
+			Target: Target{Pos: mgl.Vec2{0.3, 0.5}},
+			Radius: 200,
+		}}
+		world.AddShips(s)
+	}
+
+	var formed []*Ship
+	for i := 0; i < n; i++ {
+		s := NewShip(Others, model)
+		s.Pos = mgl.Vec2{900 + float32(i%10)*30, 100 + float32(i/10)*60}
+		s.Pilot = &SteeringPilot{
+			Move: &Orbit{Target: Target{Pos: mgl.Vec2{0.7, 0.5}},
+				Radius: 200, Clockwise: true},
+			Aim: &AimAtPlayer{TurnRate: math.Pi, FireAngle: math.Pi},
+		}
+		if i%2 == 0 {
+			formed = append(formed, s)
+		}
+		world.AddShips(s)
+	}
+	formation := &Formation{Shape: "wedge", Spacing: 40,
+		Pos: mgl.Vec2{1200, 400}, Speed: 100, StopX: 0.8}
+	formation.Form(formed, func(*Ship) Pilot { return nil })
+
+	return world
+}
+
+func TestWorldUpdateAllocs(t *testing.T) {
+	world := busyWorld(t, 20)
+	for i := 0; i < StepRate*5; i++ { // fill the pools
+		world.Update(StepDT)
+	}
+
+	allocs := testing.AllocsPerRun(StepRate, func() {
+		world.Update(StepDT)
+	})
+	if allocs > 0 {
+		t.Errorf("World.Update allocates %v times per step", allocs)
+	}
+	if len(world.missiles) == 0 {
+		t.Error("no missiles in flight")
+	}
+}
+
+func TestRamOnSpawnStep(t *testing.T) {
+	world := NewWorld(width, height, NullAudio{}, 1)
+	a := NewShip(Human, &FighterModel)
+	b := NewShip(Others, &FighterModel)
+	a.Pos = mgl.Vec2{500, 300}
+	b.Pos = mgl.Vec2{500, 310}
+	world.AddShips(a, b)
+
+	world.Update(StepDT)
+	if a.hp == FighterModel.Hp || b.hp == FighterModel.Hp {
+		t.Errorf("ships spawned on each other have %d and %d hp, want %d",
+			a.hp, b.hp, FighterModel.Hp-1)
+	}
+}